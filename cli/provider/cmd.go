@@ -173,6 +173,9 @@ func (c *CmdConfigurator) AddFlags(cmd *cobra.Command) error {
 	cmd.Flags().SetNormalizeFunc(aliasNormalizeFunc)
 	switch cmd.Name() {
 	case "update":
+		cmd.Flags().Bool("dry-run", false, "Show what the update would do without downloading or installing anything")
+		cmd.Flags().Bool("list", false, "List the available keploy releases")
+		cmd.Flags().String("to-version", "", "Install the given keploy version instead of the latest one")
 		return nil
 	case "normalize":
 		cmd.Flags().StringP("path", "p", ".", "Path to local directory where generated testcases/mocks/reports are stored")
@@ -403,6 +406,34 @@ func (c *CmdConfigurator) PreProcessFlags(cmd *cobra.Command) error {
 	}
 
 	c.cfg.ConfigPath = configPath
+
+	if err := c.expandConfigPaths(); err != nil {
+		errMsg := "failed to expand the paths in the config"
+		utils.LogError(c.logger, err, errMsg)
+		return errors.New(errMsg)
+	}
+	return nil
+}
+
+// expandConfigPaths expands a leading tilde in the path-valued config fields,
+// since values read from the config file are not expanded by the shell.
+func (c *CmdConfigurator) expandConfigPaths() error {
+	paths := []*string{
+		&c.cfg.Path,
+		&c.cfg.Test.CoverageReportPath,
+		&c.cfg.Test.JacocoAgentPath,
+		&c.cfg.Gen.SourceFilePath,
+		&c.cfg.Gen.TestFilePath,
+		&c.cfg.Gen.CoverageReportPath,
+		&c.cfg.Gen.TestDir,
+	}
+	for _, path := range paths {
+		expanded, err := utils.ExpandPath(*path)
+		if err != nil {
+			return err
+		}
+		*path = expanded
+	}
 	return nil
 }
 
@@ -17,7 +17,7 @@ func Root(ctx context.Context, logger *zap.Logger, svcFactory ServiceFactory, cm
 		Use:     "keploy",
 		Short:   "Keploy CLI",
 		Example: provider.RootExamples,
-		Version: utils.Version,
+		Version: utils.DisplayVersion(utils.Version),
 	}
 
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
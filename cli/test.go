@@ -41,7 +41,7 @@ func Test(ctx context.Context, logger *zap.Logger, _ *config.Config, serviceFact
 				case <-ctx.Done():
 					break
 				default:
-					utils.ExecCancel()
+					utils.ExecCancelWithReason("test run finished")
 				}
 			}()
 			err = replay.Start(ctx)
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/spf13/cobra"
 	"go.keploy.io/server/v2/config"
@@ -14,13 +15,16 @@ func init() {
 	Register("update", Update)
 }
 
+// listedReleases is the number of releases shown by keploy update --list.
+const listedReleases = 20
+
 // Update retrieves the command to tools Keploy
 func Update(ctx context.Context, logger *zap.Logger, _ *config.Config, serviceFactory ServiceFactory, cmdConfigurator CmdConfigurator) *cobra.Command {
 	var updateCmd = &cobra.Command{
 		Use:     "update",
 		Short:   "Update Keploy ",
 		Example: "keploy update",
-		RunE: func(_ *cobra.Command, _ []string) error {
+		RunE: func(cmd *cobra.Command, _ []string) error {
 			svc, err := serviceFactory.GetService(ctx, "update")
 			if err != nil {
 				utils.LogError(logger, err, "failed to get service")
@@ -32,9 +36,62 @@ func Update(ctx context.Context, logger *zap.Logger, _ *config.Config, serviceFa
 				utils.LogError(logger, nil, "service doesn't satisfy tools service interface")
 				return nil
 			}
+			dryRun, err := cmd.Flags().GetBool("dry-run")
+			if err != nil {
+				utils.LogError(logger, err, "failed to get dry-run flag")
+				return nil
+			}
+			list, err := cmd.Flags().GetBool("list")
+			if err != nil {
+				utils.LogError(logger, err, "failed to get list flag")
+				return nil
+			}
+			if list {
+				releases, err := utils.ListReleases(ctx, listedReleases)
+				if err != nil {
+					utils.LogErrorCtx(ctx, logger, err, "failed to list the releases")
+					return nil
+				}
+				for _, release := range releases {
+					line := utils.DisplayVersion(release.TagName)
+					if release.Prerelease {
+						line += " (pre-release)"
+					}
+					if !release.PublishedAt.IsZero() {
+						line += "\t" + release.PublishedAt.Format("2006-01-02")
+					}
+					fmt.Println(line)
+				}
+				return nil
+			}
+			if dryRun {
+				plan, err := tools.PreviewUpdate(ctx)
+				if err != nil {
+					utils.LogErrorCtx(ctx, logger, err, "failed to preview the update")
+					return nil
+				}
+				fmt.Println("Version:             " + utils.DisplayVersion(plan.Version))
+				fmt.Println("Architecture:        " + plan.Arch)
+				fmt.Println("Asset:               " + plan.AssetName)
+				fmt.Println("Download URL:        " + plan.DownloadURL)
+				fmt.Println("Target path:         " + plan.TargetPath)
+				fmt.Println("Required permission: " + plan.RequiredPermission)
+				return nil
+			}
+			toVersion, err := cmd.Flags().GetString("to-version")
+			if err != nil {
+				utils.LogError(logger, err, "failed to get to-version flag")
+				return nil
+			}
+			if toVersion != "" {
+				if err := tools.UpdateToVersion(ctx, toVersion); err != nil {
+					utils.LogErrorCtx(ctx, logger, err, "failed to install keploy "+toVersion)
+				}
+				return nil
+			}
 			err = tools.Update(ctx)
 			if err != nil {
-				utils.LogError(logger, err, "failed to update")
+				utils.LogErrorCtx(ctx, logger, err, "failed to update")
 			}
 			return nil
 		},
@@ -23,7 +23,7 @@ require (
 	github.com/spf13/cobra v1.7.0
 	go.mongodb.org/mongo-driver v1.11.6
 	go.uber.org/zap v1.24.0
-	golang.org/x/crypto v0.22.0 // indirect
+	golang.org/x/crypto v0.22.0
 	golang.org/x/sys v0.19.0
 	google.golang.org/protobuf v1.33.0 // indirect
 )
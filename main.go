@@ -9,11 +9,13 @@ import (
 
 	"go.keploy.io/server/v2/cli"
 	"go.keploy.io/server/v2/cli/provider"
-	"go.keploy.io/server/v2/config"
 	userDb "go.keploy.io/server/v2/pkg/platform/yaml/configdb/user"
+	"go.keploy.io/server/v2/pkg/service/tools"
 
+	"github.com/spf13/cobra"
 	"go.keploy.io/server/v2/utils"
 	"go.keploy.io/server/v2/utils/log"
+	"go.uber.org/zap"
 	//pprof for debugging
 	//_ "net/http/pprof"
 )
@@ -49,9 +51,15 @@ func main() {
 	// }()
 
 	printLogo()
-	ctx := utils.NewCtx()
-	utils.CheckForUpdate(ctx)
-	start(ctx)
+	ctx, _, err := utils.NewCtxChecked()
+	if err != nil {
+		fmt.Println("Failed to start keploy:", err)
+		os.Exit(1)
+	}
+	// start returns, running its deferred cleanup, before keploy exits with an error.
+	if err := start(ctx); err != nil {
+		os.Exit(1)
+	}
 }
 
 func printLogo() {
@@ -61,16 +69,27 @@ func printLogo() {
 	utils.Version = version
 	if binaryToDocker := os.Getenv("BINARY_TO_DOCKER"); binaryToDocker != "true" {
 		fmt.Println(logo, " ")
-		fmt.Printf("version: %v\n\n", version)
+		fmt.Printf("version: %v\n\n", utils.DisplayVersion(version))
 	}
 }
 
-func start(ctx context.Context) {
+func start(ctx context.Context) error {
 	logger, err := log.New()
 	if err != nil {
 		fmt.Println("Failed to start the logger for the CLI", err)
-		return
+		return err
 	}
+	utils.SetLogger(logger)
+	defer utils.MarkStopped()
+	if err := utils.MigrateLegacyBaseDir(logger); err != nil {
+		utils.LogError(logger, err, "failed to migrate the keploy settings")
+	}
+	if _, err := utils.EnsureBaseDir(); err != nil {
+		utils.LogError(logger, err, "failed to set up the keploy home directory")
+		return err
+	}
+	go utils.WatchConfigReload(ctx, logger)
+	utils.SetAutoUpdater(tools.NewTools(logger, nil).UpdateToVersion)
 	defer func() {
 		if err := utils.DeleteFileIfNotExists(logger, "keploy-logs.txt"); err != nil {
 			utils.LogError(logger, err, "Failed to delete Keploy Logs")
@@ -99,16 +118,37 @@ func start(ctx context.Context) {
 		utils.SentryInit(logger, dsn)
 		//logger = utils.ModifyToSentryLogger(ctx, logger, sentry.CurrentHub().Client(), configDb)
 	}
-	conf := config.New()
+	// The config set up by NewCtxChecked is filled in place from the flags and the config
+	// file once the command runs, so the handlers reading it from the context see the
+	// loaded values.
+	conf := utils.ConfigFrom(ctx)
 
 	svcProvider := provider.NewServiceProvider(logger, userDb, conf)
 	cmdConfigurator := provider.NewCmdConfigurator(logger, conf)
 	rootCmd := cli.Root(ctx, logger, svcProvider, cmdConfigurator)
+
+	if err := utils.CheckForUpdate(ctx, logger); err != nil {
+		// keploy update is how the user gets out of a mandatory update, it must run.
+		if !isUpdateCommand(rootCmd, os.Args[1:]) {
+			utils.LogErrorCtx(ctx, logger, err, "please update keploy to continue")
+			return err
+		}
+		logger.Warn("a mandatory update is required, updating keploy", zap.Error(err))
+	}
+
 	if err := rootCmd.Execute(); err != nil {
 		if strings.HasPrefix(err.Error(), "unknown command") || strings.HasPrefix(err.Error(), "unknown shorthand") {
 			fmt.Println("Error: ", err.Error())
 			fmt.Println("Run 'keploy --help' for usage.")
-			os.Exit(1)
+			return err
 		}
 	}
+	return nil
+}
+
+// isUpdateCommand reports whether the command line runs keploy update. The command is
+// found the way cobra finds it, so that the value of a flag isn't taken for it.
+func isUpdateCommand(root *cobra.Command, args []string) bool {
+	cmd, _, err := root.Find(args)
+	return err == nil && cmd.Name() == "update"
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestIsUpdateCommand(t *testing.T) {
+	root := &cobra.Command{Use: "keploy"}
+	root.PersistentFlags().Bool("debug", false, "")
+	root.Flags().StringP("config-path", "c", "", "")
+	record := &cobra.Command{Use: "record", Run: func(*cobra.Command, []string) {}}
+	record.Flags().StringP("command", "c", "", "")
+	update := &cobra.Command{Use: "update", Run: func(*cobra.Command, []string) {}}
+	update.Flags().String("to-version", "", "")
+	root.AddCommand(record, update)
+
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"update"}, true},
+		{[]string{"--debug", "update"}, true},
+		{[]string{"update", "--to-version", "v1.2.0"}, true},
+		{[]string{"-c", "path", "update"}, true},
+		{[]string{"--config-path", "path", "update"}, true},
+		{[]string{"-c", "update", "record"}, false},
+		{[]string{"record", "-c", "update"}, false},
+		{[]string{"--help"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isUpdateCommand(root, tt.args); got != tt.want {
+			t.Errorf("isUpdateCommand(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
@@ -176,7 +176,7 @@ func (r *mutationResolver) StopHooks(context.Context) (bool, error) {
 		return false, err
 	}
 	r.logger.Debug("stopping the hooks")
-	err := utils.Stop(r.logger, "stopping the test run")
+	err := utils.StopWithReason(r.logger, utils.ReasonUserRequest, "stopping the test run")
 	if err != nil {
 		utils.LogError(r.logger, err, "failed to stop the test run")
 		return false, err
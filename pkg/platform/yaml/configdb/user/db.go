@@ -9,7 +9,6 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
-	"runtime"
 
 	"go.keploy.io/server/v2/pkg/platform/yaml"
 	"go.keploy.io/server/v2/utils"
@@ -23,16 +22,7 @@ type Db struct {
 }
 
 func HomeDir() string {
-
-	configFolder := "/.keploy"
-	if runtime.GOOS == "windows" {
-		home := os.Getenv("HOMEDRIVE") + os.Getenv("HOMEPATH")
-		if home == "" {
-			home = os.Getenv("USERPROFILE")
-		}
-		return home + configFolder
-	}
-	return os.Getenv("HOME") + configFolder
+	return utils.BaseDir()
 }
 
 func New(logger *zap.Logger) *Db {
@@ -187,7 +187,7 @@ func (r *Recorder) Start(ctx context.Context, reRecord bool) error {
 			select {
 			case <-timer:
 				r.logger.Warn("Time up! Stopping keploy")
-				err := utils.Stop(r.logger, "Time up! Stopping keploy")
+				err := utils.StopWithReason(r.logger, utils.ReasonCompleted, "Time up! Stopping keploy")
 				if err != nil {
 					utils.LogError(r.logger, err, "failed to stop recording")
 					return errors.New("failed to stop recording")
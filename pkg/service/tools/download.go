@@ -0,0 +1,325 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.keploy.io/server/v2/utils"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxDownloadAttempts is the number of times a release asset download is attempted
+// before giving up. Every attempt after the first resumes from the partial file if
+// the server supports range requests.
+const maxDownloadAttempts = 3
+
+// downloadRetryDelay is the pause between two download attempts.
+var downloadRetryDelay = 2 * time.Second
+
+// partialDownloadPath returns the stable location of the partially downloaded asset of
+// a version, so that a failed transfer can be resumed by a later attempt, or by a later
+// run after a crash. It lives in a directory of the keploy home only the user can
+// access, a shared directory such as /tmp would let other users plant a partial file.
+// A download canceled by the user is not kept.
+func partialDownloadPath(version, assetName string) (string, error) {
+	dir := utils.StatePath(utils.DownloadDir)
+	if err := utils.EnsureStateDir(dir); err != nil {
+		return "", fmt.Errorf("failed to create the download directory: %w", err)
+	}
+	// EnsureStateDir keeps the permissions of an existing directory.
+	if err := os.Chmod(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to restrict the permissions of the download directory: %w", err)
+	}
+	return filepath.Join(dir, "keploy-"+version+"-"+assetName+".partial"), nil
+}
+
+// downloadWithResume downloads the file at url into dest. If dest already holds a
+// partial download, only the remaining bytes are requested using an HTTP Range request.
+// If the server ignores the range, or can't satisfy it, the download is restarted from
+// scratch. The final size is validated against the size announced by the server, the
+// caller must verify the checksum with verifyDownload.
+func downloadWithResume(ctx context.Context, logger *zap.Logger, url, dest string) error {
+	var lastErr error
+	for attempt := 1; attempt <= maxDownloadAttempts; attempt++ {
+		if attempt > 1 {
+			logger.Info("retrying download", zap.Int("attempt", attempt), zap.Error(lastErr))
+			select {
+			case <-ctx.Done():
+				return abortDownload(ctx, logger, dest)
+			case <-time.After(downloadRetryDelay):
+			}
+		}
+
+		lastErr = downloadOnce(ctx, logger, url, dest)
+		if lastErr == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return abortDownload(ctx, logger, dest)
+		}
+	}
+	if lastErr != nil {
+		return fmt.Errorf("failed to download %s after %d attempts: %w", url, maxDownloadAttempts, lastErr)
+	}
+	return nil
+}
+
+// abortDownload removes the partial file of a download canceled through its context,
+// e.g. by a Ctrl-C during the update, and returns the context error. A download stopped
+// because another fetch of the update failed keeps its partial file to be resumed.
+func abortDownload(ctx context.Context, logger *zap.Logger, dest string) error {
+	var failed *fetchFailedError
+	if errors.As(context.Cause(ctx), &failed) {
+		return ctx.Err()
+	}
+	if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+		utils.LogError(logger, err, "failed to remove the partial download", zap.String("path", dest))
+	}
+	return ctx.Err()
+}
+
+// ctxReader stops reading as soon as its context is done, so that a canceled download
+// doesn't wait for the transfer to complete.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// verifyDownload verifies the checksum of a downloaded file and removes the file if it
+// doesn't match. A file without a checksum is never trusted.
+func verifyDownload(logger *zap.Logger, url, dest, expectedSum string) error {
+	if expectedSum == "" {
+		return fmt.Errorf("no checksum available to verify %s", url)
+	}
+	if err := verifyChecksum(dest, expectedSum); err != nil {
+		// A corrupted partial file must not be resumed again.
+		if rerr := os.Remove(dest); rerr != nil {
+			utils.LogError(logger, rerr, "failed to remove corrupted download", zap.String("path", dest))
+		}
+		return err
+	}
+	return nil
+}
+
+const (
+	// defaultDownloadConcurrency is the number of release files fetched at the same
+	// time when download_concurrency isn't set.
+	defaultDownloadConcurrency = 2
+	// maxDownloadConcurrency bounds download_concurrency to stay polite with the server.
+	maxDownloadConcurrency = 8
+)
+
+// downloadConcurrency returns the download_concurrency setting.
+func downloadConcurrency(logger *zap.Logger) int {
+	n, err := utils.GetIntInRange("download_concurrency", 1, maxDownloadConcurrency, defaultDownloadConcurrency)
+	if err != nil {
+		logger.Warn("invalid download_concurrency in the keploy config", zap.Int("using", n), zap.Error(err))
+	}
+	return n
+}
+
+// fetchFailedError is the cause of the cancellation of the fetches stopped by the
+// failure of another one, unlike a cancellation by the user.
+type fetchFailedError struct {
+	err error
+}
+
+func (e *fetchFailedError) Error() string {
+	return "another fetch failed: " + e.err.Error()
+}
+
+// fetchConcurrently runs the fetches with at most limit of them at the same time. The
+// first error cancels the context passed to the remaining fetches and is returned.
+func fetchConcurrently(ctx context.Context, limit int, fetches ...func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var g errgroup.Group
+	g.SetLimit(limit)
+	for _, fetch := range fetches {
+		g.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fetch(ctx); err != nil {
+				cancel(&fetchFailedError{err: err})
+				return err
+			}
+			return nil
+		})
+	}
+	err := g.Wait()
+	// A fetch stopped by the failure can return before it, the failure is what matters.
+	var failed *fetchFailedError
+	if errors.As(context.Cause(ctx), &failed) {
+		return failed.err
+	}
+	return err
+}
+
+// downloadOnce performs a single (possibly resumed) download attempt.
+func downloadOnce(ctx context.Context, logger *zap.Logger, url, dest string) error {
+	var offset int64
+	if info, err := os.Stat(dest); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	client := utils.HTTPClient(0)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			utils.LogError(logger, cerr, "failed to close response body")
+		}
+	}()
+
+	var total int64
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		total, err = totalFromContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		logger.Info("resuming download", zap.Int64("offset", offset), zap.Int64("total", total))
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		if offset > 0 {
+			logger.Debug("server does not support range requests, restarting the download")
+		}
+		offset = 0
+		total = resp.ContentLength
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is not a prefix of the asset, e.g. it is larger, so it
+		// can't be resumed: it is discarded and the download restarted.
+		if offset == 0 {
+			return fmt.Errorf("unexpected status code %d while downloading %s", resp.StatusCode, url)
+		}
+		logger.Debug("the partial download can't be resumed, restarting the download", zap.Int64("offset", offset))
+		if err := os.Remove(dest); err != nil {
+			return fmt.Errorf("failed to remove the partial download: %w", err)
+		}
+		return downloadOnce(ctx, logger, url, dest)
+	default:
+		return fmt.Errorf("unexpected status code %d while downloading %s", resp.StatusCode, url)
+	}
+
+	file, err := os.OpenFile(dest, flags, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open download file: %v", err)
+	}
+	written, copyErr := io.Copy(file, ctxReader{ctx: ctx, r: resp.Body})
+	if err := file.Close(); err != nil {
+		utils.LogError(logger, err, "failed to close download file")
+	}
+	if copyErr != nil {
+		return fmt.Errorf("failed to write to download file: %w", copyErr)
+	}
+
+	if total > 0 && offset+written != total {
+		return fmt.Errorf("incomplete download: got %d of %d bytes", offset+written, total)
+	}
+	return nil
+}
+
+// totalFromContentRange parses the complete length from a "bytes start-end/total" header.
+func totalFromContentRange(header string) (int64, error) {
+	idx := strings.LastIndex(header, "/")
+	if idx == -1 {
+		return 0, fmt.Errorf("invalid Content-Range header: %q", header)
+	}
+	totalStr := header[idx+1:]
+	if totalStr == "*" {
+		return -1, nil
+	}
+	total, err := strconv.ParseInt(totalStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Content-Range header: %q", header)
+	}
+	return total, nil
+}
+
+// verifyChecksum compares the SHA-256 checksum of the file at path with the expected hex digest.
+func verifyChecksum(path, expected string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			utils.LogError(nil, err, "failed to close file")
+		}
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filepath.Base(path), expected, actual)
+	}
+	return nil
+}
+
+// fetchChecksum downloads the goreleaser checksums file and returns the digest for assetName.
+func fetchChecksum(ctx context.Context, logger *zap.Logger, checksumURL, assetName string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checksumURL, nil)
+	if err != nil {
+		return "", err
+	}
+	client := utils.HTTPClient(10 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			utils.LogError(logger, cerr, "failed to close response body")
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d while fetching checksums", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == assetName {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("checksum not found for " + assetName)
+}
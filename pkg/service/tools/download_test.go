@@ -0,0 +1,260 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.keploy.io/server/v2/utils"
+	"go.uber.org/zap"
+)
+
+// testAsset is the content served as the release asset.
+var testAsset = bytes.Repeat([]byte("keploy release asset "), 4096)
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// serveAsset serves testAsset with range support. The first response is cut after
+// half of the asset when interrupt is set, like a connection dropped mid-transfer.
+func serveAsset(t *testing.T, interrupt bool) (*httptest.Server, *[]string) {
+	t.Helper()
+	var ranges []string
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if interrupt && requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", "99999999")
+			_, _ = w.Write(testAsset[:len(testAsset)/2])
+			return
+		}
+		http.ServeContent(w, r, "asset", time.Time{}, bytes.NewReader(testAsset))
+	}))
+	t.Cleanup(server.Close)
+	return server, &ranges
+}
+
+func noRetryDelay(t *testing.T) {
+	t.Helper()
+	previous := downloadRetryDelay
+	downloadRetryDelay = 0
+	t.Cleanup(func() { downloadRetryDelay = previous })
+}
+
+func TestDownloadResumesInterruptedTransfer(t *testing.T) {
+	noRetryDelay(t)
+	server, ranges := serveAsset(t, true)
+	dest := filepath.Join(t.TempDir(), "asset.partial")
+
+	if err := downloadWithResume(context.Background(), zap.NewNop(), server.URL, dest); err != nil {
+		t.Fatalf("downloadWithResume() error = %v", err)
+	}
+	if err := verifyDownload(zap.NewNop(), server.URL, dest, sha256Hex(testAsset)); err != nil {
+		t.Fatalf("verifyDownload() error = %v", err)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, testAsset) {
+		t.Fatalf("downloaded %d bytes which differ from the %d bytes of the asset", len(got), len(testAsset))
+	}
+	if len(*ranges) != 2 || (*ranges)[1] != "bytes="+strconv.Itoa(len(testAsset)/2)+"-" {
+		t.Fatalf("Range headers = %q, want the second request to resume from the middle", *ranges)
+	}
+}
+
+func TestDownloadRestartsUnsatisfiableRange(t *testing.T) {
+	server, ranges := serveAsset(t, false)
+	dest := filepath.Join(t.TempDir(), "asset.partial")
+	// A partial file larger than the asset, e.g. left by another version.
+	if err := os.WriteFile(dest, bytes.Repeat([]byte("x"), len(testAsset)+10), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := downloadWithResume(context.Background(), zap.NewNop(), server.URL, dest); err != nil {
+		t.Fatalf("downloadWithResume() error = %v", err)
+	}
+	if err := verifyDownload(zap.NewNop(), server.URL, dest, sha256Hex(testAsset)); err != nil {
+		t.Fatalf("verifyDownload() error = %v", err)
+	}
+	if len(*ranges) != 2 || (*ranges)[1] != "" {
+		t.Fatalf("Range headers = %q, want the download restarted without a range", *ranges)
+	}
+}
+
+func TestVerifyDownloadChecksumMismatch(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "asset.partial")
+	if err := os.WriteFile(dest, []byte("tampered"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := verifyDownload(zap.NewNop(), "https://example.com/asset", dest, sha256Hex(testAsset))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("verifyDownload() error = %v, want a checksum mismatch", err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("the corrupted download was kept: %v", err)
+	}
+}
+
+func TestVerifyDownloadMissingChecksum(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "asset.partial")
+	if err := os.WriteFile(dest, testAsset, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyDownload(zap.NewNop(), "https://example.com/asset", dest, ""); err == nil {
+		t.Fatal("verifyDownload() accepted a download without a checksum")
+	}
+}
+
+func TestPartialDownloadPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("KEPLOY_HOME", home)
+
+	v1, err := partialDownloadPath("v1.0.0", "keploy_linux_amd64.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := partialDownloadPath("v1.1.0", "keploy_linux_amd64.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1 == v2 {
+		t.Fatalf("the partial downloads of two versions share %s", v1)
+	}
+	if !strings.HasPrefix(v1, home) {
+		t.Fatalf("partial download %s is outside the keploy home %s", v1, home)
+	}
+	info, err := os.Stat(filepath.Dir(v1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Fatalf("download directory permissions = %v, want 0700", perm)
+	}
+}
+
+func TestFetchConcurrentlyLimit(t *testing.T) {
+	var running, peak atomic.Int32
+	fetch := func(context.Context) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+
+	if err := fetchConcurrently(context.Background(), 2, fetch, fetch, fetch, fetch); err != nil {
+		t.Fatal(err)
+	}
+	if got := peak.Load(); got != 2 {
+		t.Fatalf("%d fetches ran at the same time, want the limit of 2", got)
+	}
+}
+
+func TestFetchConcurrentlyFirstErrorCancels(t *testing.T) {
+	failure := errors.New("checksum unavailable")
+	err := fetchConcurrently(context.Background(), 2,
+		func(context.Context) error { return failure },
+		func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+				return errors.New("the fetch was not canceled")
+			}
+		},
+	)
+	if !errors.Is(err, failure) {
+		t.Fatalf("fetchConcurrently() error = %v, want the first error", err)
+	}
+}
+
+func TestDownloadConcurrency(t *testing.T) {
+	for config, want := range map[string]int{
+		"":                          defaultDownloadConcurrency,
+		"download_concurrency=4\n":  4,
+		"download_concurrency=0\n":  1,
+		"download_concurrency=20\n": maxDownloadConcurrency,
+		"download_concurrency=x\n":  defaultDownloadConcurrency,
+	} {
+		t.Setenv("KEPLOY_HOME", t.TempDir())
+		if err := os.WriteFile(utils.KeployConfigPath(), []byte(config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := utils.ReloadConfig(); err != nil {
+			t.Fatal(err)
+		}
+		if got := downloadConcurrency(zap.NewNop()); got != want {
+			t.Errorf("downloadConcurrency() = %d with %q, want %d", got, config, want)
+		}
+	}
+}
+
+func TestDownloadCanceledRemovesPartialFile(t *testing.T) {
+	noRetryDelay(t)
+	server, _ := serveAsset(t, true)
+	dest := filepath.Join(t.TempDir(), "asset.partial")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := downloadWithResume(ctx, zap.NewNop(), server.URL, dest); err != context.Canceled {
+		t.Fatalf("downloadWithResume() error = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("the partial download was kept after the cancellation: %v", err)
+	}
+}
+
+func TestFetchFailureKeepsPartialFile(t *testing.T) {
+	noRetryDelay(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(testAsset)))
+		_, _ = w.Write(testAsset[:len(testAsset)/2])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	dest := filepath.Join(t.TempDir(), "asset.partial")
+	errChecksum := errors.New("checksum fetch failed")
+
+	err := fetchConcurrently(context.Background(), 2,
+		func(ctx context.Context) error {
+			return downloadWithResume(ctx, zap.NewNop(), server.URL, dest)
+		},
+		func(context.Context) error {
+			// Fail once the download is under way.
+			for {
+				if info, err := os.Stat(dest); err == nil && info.Size() > 0 {
+					return errChecksum
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		},
+	)
+	if !errors.Is(err, errChecksum) {
+		t.Fatalf("fetchConcurrently() error = %v, want the checksum failure", err)
+	}
+	if _, err := os.Stat(dest); err != nil {
+		t.Fatalf("the partial download was removed after a failed checksum fetch: %v", err)
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 type Service interface {
 	Update(ctx context.Context) error
+	UpdateToVersion(ctx context.Context, version string) error
+	PreviewUpdate(ctx context.Context) (UpdatePlan, error)
 	CreateConfig(ctx context.Context, filePath string, config string) error
 	SendTelemetry(event string, output ...map[string]interface{})
 }
@@ -14,3 +16,8 @@ type Service interface {
 type teleDB interface {
 	SendTelemetry(event string, output ...map[string]interface{})
 }
+
+// noopTelemetry is the teleDB sending nothing.
+type noopTelemetry struct{}
+
+func (noopTelemetry) SendTelemetry(string, ...map[string]interface{}) {}
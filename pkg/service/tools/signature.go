@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.keploy.io/server/v2/utils"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/blake2b"
+)
+
+// signingPublicKey is the minisign public key the release assets are signed with, in
+// its base64 form. It is injected at build time like the version, with
+// -ldflags "-X go.keploy.io/server/v2/pkg/service/tools.signingPublicKey=<key>".
+// Without it, signature verification is off unless verify_signature=true, which fails
+// the update.
+var signingPublicKey = ""
+
+// signatureSuffix is appended to the URL of an asset to get its detached signature.
+const signatureSuffix = ".minisig"
+
+// maxSignatureSize is the largest signature file accepted, a minisign one is ~300 bytes.
+const maxSignatureSize = 4096
+
+// Minisign signature algorithms: "Ed" signs the file, "ED" signs its BLAKE2b-512 hash.
+var (
+	minisignAlgLegacy    = []byte("Ed")
+	minisignAlgPrehashed = []byte("ED")
+)
+
+// signatureVerificationEnabled returns the verify_signature setting, which defaults to
+// true once a signing key is bundled.
+func signatureVerificationEnabled(logger *zap.Logger) bool {
+	def := signingPublicKey != ""
+	value := strings.TrimSpace(utils.GetString("verify_signature"))
+	if value == "" {
+		return def
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Warn("invalid verify_signature in the keploy config, expected true or false", zap.String("value", value), zap.Bool("default", def))
+		return def
+	}
+	return enabled
+}
+
+// fetchSignature downloads the detached signature of an asset.
+func fetchSignature(ctx context.Context, logger *zap.Logger, signatureURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, signatureURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := utils.HTTPClient(10 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			utils.LogError(logger, cerr, "failed to close response body")
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d while fetching the signature", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSignatureSize))
+}
+
+// verifySignature verifies the minisign signature of the file at path with the base64
+// minisign public key.
+func verifySignature(path string, signature []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != 2+8+ed25519.PublicKeySize || !bytes.Equal(key[:2], minisignAlgLegacy) {
+		return errors.New("invalid signing public key")
+	}
+	keyID, pub := key[2:10], ed25519.PublicKey(key[10:])
+
+	lines := strings.Split(strings.ReplaceAll(string(signature), "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("malformed signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return errors.New("malformed signature")
+	}
+	if !bytes.Equal(sig[2:10], keyID) {
+		return errors.New("the signature was made with another key")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch {
+	case bytes.Equal(sig[:2], minisignAlgPrehashed):
+		sum := blake2b.Sum512(data)
+		data = sum[:]
+	case !bytes.Equal(sig[:2], minisignAlgLegacy):
+		return errors.New("unsupported signature algorithm")
+	}
+	if !ed25519.Verify(pub, data, sig[10:]) {
+		return errors.New("invalid signature")
+	}
+
+	// The global signature covers the signature and the trusted comment, which holds
+	// the file name and the timestamp of the signature.
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return errors.New("malformed signature")
+	}
+	trustedComment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(pub, append(sig[10:], trustedComment...), globalSig) {
+		return errors.New("invalid trusted comment signature")
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/blake2b"
+)
+
+// testSigner is a minisign key pair generated for a test.
+type testSigner struct {
+	keyID []byte
+	priv  ed25519.PrivateKey
+	// publicKey is the base64 minisign public key.
+	publicKey string
+}
+
+func newTestSigner(t *testing.T) testSigner {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := []byte("keyid-01")
+	key := append(append(append([]byte(nil), minisignAlgLegacy...), keyID...), pub...)
+	return testSigner{keyID: keyID, priv: priv, publicKey: base64.StdEncoding.EncodeToString(key)}
+}
+
+// sign returns the minisign signature of data, prehashed as done by default.
+func (s testSigner) sign(data []byte) []byte {
+	sum := blake2b.Sum512(data)
+	sig := append(append(append([]byte(nil), minisignAlgPrehashed...), s.keyID...), ed25519.Sign(s.priv, sum[:])...)
+	trustedComment := "timestamp:1700000000\tfile:keploy_linux_amd64.tar.gz"
+	globalSig := ed25519.Sign(s.priv, append(append([]byte(nil), sig[10:]...), trustedComment...))
+	return []byte("untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(sig) + "\n" +
+		"trusted comment: " + trustedComment + "\n" +
+		base64.StdEncoding.EncodeToString(globalSig) + "\n")
+}
+
+func writeAsset(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "asset.tar.gz")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifySignatureValid(t *testing.T) {
+	signer := newTestSigner(t)
+	path := writeAsset(t, testAsset)
+
+	if err := verifySignature(path, signer.sign(testAsset), signer.publicKey); err != nil {
+		t.Fatalf("verifySignature() error = %v", err)
+	}
+}
+
+func TestVerifySignatureInvalid(t *testing.T) {
+	signer := newTestSigner(t)
+	path := writeAsset(t, append([]byte("tampered"), testAsset...))
+
+	if err := verifySignature(path, signer.sign(testAsset), signer.publicKey); err == nil {
+		t.Fatal("verifySignature() accepted the signature of another file")
+	}
+}
+
+func TestVerifySignatureOtherKey(t *testing.T) {
+	signer, other := newTestSigner(t), newTestSigner(t)
+	path := writeAsset(t, testAsset)
+
+	if err := verifySignature(path, other.sign(testAsset), signer.publicKey); err == nil {
+		t.Fatal("verifySignature() accepted a signature made with another key")
+	}
+}
+
+// useSigningKey sets the bundled signing key for the duration of the test.
+func useSigningKey(t *testing.T, key string) {
+	t.Helper()
+	previous := signingPublicKey
+	signingPublicKey = key
+	t.Cleanup(func() { signingPublicKey = previous })
+}
+
+// serveRelease serves the asset, its checksums and, when signature isn't nil, its
+// signature, and returns the plan updating the target binary from them.
+func serveRelease(t *testing.T, signature []byte) (UpdatePlan, string) {
+	t.Helper()
+	const assetName = "keploy_linux_amd64.tar.gz"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+assetName, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(testAsset)
+	})
+	mux.HandleFunc("/checksums.txt", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(sha256Hex(testAsset) + "  " + assetName + "\n"))
+	})
+	mux.HandleFunc("/"+assetName+signatureSuffix, func(w http.ResponseWriter, _ *http.Request) {
+		if signature == nil {
+			http.NotFound(w, nil)
+			return
+		}
+		_, _ = w.Write(signature)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	t.Setenv("KEPLOY_HOME", t.TempDir())
+	target := filepath.Join(t.TempDir(), "keploy")
+	if err := os.WriteFile(target, []byte("current keploy"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return UpdatePlan{
+		Version:      "v1.2.0",
+		AssetName:    assetName,
+		DownloadURL:  server.URL + "/" + assetName,
+		ChecksumURL:  server.URL + "/checksums.txt",
+		SignatureURL: server.URL + "/" + assetName + signatureSuffix,
+		TargetPath:   target,
+	}, target
+}
+
+func assertNotReplaced(t *testing.T, target string) {
+	t.Helper()
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "current keploy" {
+		t.Fatal("the keploy binary was replaced by an unverified update")
+	}
+}
+
+func TestUpdateBinaryMissingSignature(t *testing.T) {
+	useSigningKey(t, newTestSigner(t).publicKey)
+	plan, target := serveRelease(t, nil)
+
+	err := (&Tools{logger: zap.NewNop()}).UpdateBinary(context.Background(), plan)
+	if err == nil || !strings.Contains(err.Error(), "without a valid signature") {
+		t.Fatalf("UpdateBinary() error = %v, want a missing signature error", err)
+	}
+	assertNotReplaced(t, target)
+}
+
+func TestUpdateBinaryInvalidSignature(t *testing.T) {
+	signer := newTestSigner(t)
+	useSigningKey(t, signer.publicKey)
+	plan, target := serveRelease(t, signer.sign([]byte("another asset")))
+
+	err := (&Tools{logger: zap.NewNop()}).UpdateBinary(context.Background(), plan)
+	if err == nil || !strings.Contains(err.Error(), "invalid signature") {
+		t.Fatalf("UpdateBinary() error = %v, want an invalid signature error", err)
+	}
+	assertNotReplaced(t, target)
+}
@@ -8,9 +8,7 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
-	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -22,7 +20,12 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// NewTools returns the tools service. A nil telemetry, e.g. for the auto-updater set up
+// before the telemetry exists, sends nothing.
 func NewTools(logger *zap.Logger, telemetry teleDB) Service {
+	if telemetry == nil {
+		telemetry = noopTelemetry{}
+	}
 	return &Tools{
 		logger:    logger,
 		telemetry: telemetry,
@@ -34,7 +37,10 @@ type Tools struct {
 	telemetry teleDB
 }
 
-var ErrGitHubAPIUnresponsive = errors.New("GitHub API is unresponsive")
+// releaseDownloadURL is the base URL of the assets attached to the keploy releases,
+// followed by the release tag. The tag is always given, even when updating to the latest
+// release, so that a release published in the middle of an update can't mix versions.
+const releaseDownloadURL = "https://github.com/keploy/keploy/releases/download/"
 
 func (t *Tools) SendTelemetry(event string, output ...map[string]interface{}) {
 	t.telemetry.SendTelemetry(event, output...)
@@ -53,10 +59,10 @@ func (t *Tools) Update(ctx context.Context) error {
 		return nil
 	}
 
-	releaseInfo, err := utils.GetLatestGitHubRelease(ctx, t.logger)
+	releaseInfo, err := utils.GetLatestGitHubReleaseWithProgress(ctx, t.logger)
 	if err != nil {
-		if errors.Is(err, ErrGitHubAPIUnresponsive) {
-			return errors.New("gitHub API is unresponsive. Update process cannot continue")
+		if errors.Is(err, utils.ErrGitHubAPIUnresponsive) {
+			return fmt.Errorf("update process cannot continue: %w", err)
 		}
 		return fmt.Errorf("failed to fetch latest GitHub release version: %v", err)
 	}
@@ -64,20 +70,18 @@ func (t *Tools) Update(ctx context.Context) error {
 	latestVersion := releaseInfo.TagName
 	changelog := releaseInfo.Body
 
-	if currentVersion == latestVersion {
-		fmt.Println("✅You are already on the latest version of Keploy: " + latestVersion)
+	if cmp, err := utils.CompareVersions(currentVersion, latestVersion); err == nil && cmp >= 0 {
+		fmt.Println("✅You are already on the latest version of Keploy: " + utils.DisplayVersion(latestVersion))
 		return nil
 	}
 
-	t.logger.Info("Updating to Version: " + latestVersion)
+	t.logger.Info("Updating to Version: " + utils.DisplayVersion(latestVersion))
 
-	downloadURL := ""
-	if runtime.GOARCH == "amd64" {
-		downloadURL = "https://github.com/keploy/keploy/releases/latest/download/keploy_linux_amd64.tar.gz"
-	} else {
-		downloadURL = "https://github.com/keploy/keploy/releases/latest/download/keploy_linux_arm64.tar.gz"
+	plan, err := planUpdate(latestVersion, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
 	}
-	err = t.downloadAndUpdate(ctx, t.logger, downloadURL)
+	err = t.UpdateBinary(ctx, plan)
 	if err != nil {
 		return err
 	}
@@ -104,69 +108,213 @@ func (t *Tools) Update(ctx context.Context) error {
 	return nil
 }
 
-func (t *Tools) downloadAndUpdate(ctx context.Context, logger *zap.Logger, downloadURL string) error {
-	// Create a new request with context
-	req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
+// UpdateToVersion installs the given keploy version instead of the latest one, e.g. to
+// pin a version or reproduce a bug. The version must match an existing release, it is
+// checked before anything is downloaded.
+func (t *Tools) UpdateToVersion(ctx context.Context, version string) error {
+	if len(os.Getenv("KEPLOY_INDOCKER")) > 0 {
+		fmt.Println("As you are using docker version of keploy, please pull the keploy Docker image with the " + version + " tag")
+		return nil
+	}
+
+	release, err := utils.FindRelease(ctx, t.logger, version)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return err
+	}
+	if cmp, err := utils.CompareVersions(utils.Version, release.TagName); err == nil && cmp == 0 {
+		fmt.Println("✅You are already on version " + utils.DisplayVersion(release.TagName) + " of Keploy")
+		return nil
 	}
 
-	// Create a HTTP client and execute the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	t.logger.Info("Installing Version: " + utils.DisplayVersion(release.TagName))
+	plan, err := planUpdate(release.TagName, runtime.GOOS, runtime.GOARCH)
 	if err != nil {
-		return fmt.Errorf("failed to download file: %v", err)
+		return err
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			utils.LogError(logger, cerr, "failed to close response body")
-		}
-	}()
+	if err := t.UpdateBinary(ctx, plan); err != nil {
+		return err
+	}
+	t.logger.Info("Installed keploy " + utils.DisplayVersion(release.TagName))
+	return nil
+}
 
-	// Create a temporary file to store the downloaded tar.gz
-	tmpFile, err := os.CreateTemp("", "keploy-download-*.tar.gz")
+// UpdatePlan describes what a binary update does: which release asset is downloaded
+// from where, and which file it replaces.
+type UpdatePlan struct {
+	Version string
+	// Arch is the architecture of the downloaded asset, all for the universal macOS binary.
+	Arch        string
+	AssetName   string
+	DownloadURL string
+	ChecksumURL string
+	// SignatureURL is the detached minisign signature of the asset.
+	SignatureURL string
+	// TargetPath is the installed keploy binary which gets replaced.
+	TargetPath string
+	// RequiredPermission describes the access needed to replace the target.
+	RequiredPermission string
+}
+
+// PreviewUpdate resolves the update plan for the latest release without downloading
+// or writing anything.
+func (t *Tools) PreviewUpdate(ctx context.Context) (UpdatePlan, error) {
+	releaseInfo, err := utils.GetLatestGitHubRelease(ctx, t.logger)
 	if err != nil {
-		return fmt.Errorf("failed to create temporary file: %v", err)
+		return UpdatePlan{}, fmt.Errorf("failed to fetch latest GitHub release version: %w", err)
 	}
-	defer func() {
-		if err := tmpFile.Close(); err != nil {
-			utils.LogError(logger, err, "failed to close temporary file")
-		}
-		if err := os.Remove(tmpFile.Name()); err != nil {
-			utils.LogError(logger, err, "failed to remove temporary file")
-		}
-	}()
+	return planUpdate(releaseInfo.TagName, runtime.GOOS, runtime.GOARCH)
+}
 
-	// Write the downloaded content to the temporary file
-	_, err = io.Copy(tmpFile, resp.Body)
+// AssetFor returns the name of the release archive for the given platform.
+func AssetFor(goos, goarch string) (string, error) {
+	return utils.ReleaseAssetName(goos, goarch)
+}
+
+// assetArch returns the architecture of a release asset named keploy_<os>_<arch>.tar.gz.
+func assetArch(assetName string) string {
+	name := strings.TrimSuffix(assetName, ".tar.gz")
+	return name[strings.LastIndex(name, "_")+1:]
+}
+
+// planUpdate computes the update plan of the given version for a platform.
+func planUpdate(version, goos, goarch string) (UpdatePlan, error) {
+	assetName, err := AssetFor(goos, goarch)
+	if err != nil {
+		return UpdatePlan{}, err
+	}
+	targetPath, err := ResolveInstallTarget()
 	if err != nil {
-		return fmt.Errorf("failed to write to temporary file: %v", err)
+		return UpdatePlan{}, err
 	}
+	return UpdatePlan{
+		Version:            version,
+		Arch:               assetArch(assetName),
+		AssetName:          assetName,
+		DownloadURL:        releaseDownloadURL + version + "/" + assetName,
+		ChecksumURL:        releaseDownloadURL + version + "/keploy_" + strings.TrimPrefix(version, "v") + "_checksums.txt",
+		SignatureURL:       releaseDownloadURL + version + "/" + assetName + signatureSuffix,
+		TargetPath:         targetPath,
+		RequiredPermission: "write access to " + filepath.Dir(targetPath),
+	}, nil
+}
 
-	// Extract the tar.gz file
-	if err := extractTarGz(tmpFile.Name(), "/tmp"); err != nil {
-		return fmt.Errorf("failed to extract tar.gz file: %v", err)
+// ResolveInstallTarget returns the real path of the running keploy binary, which is the
+// file to replace on update: symlinks are resolved, so that the update replaces the
+// binary and not the link. Whether the binary can be replaced is only checked by
+// UpdateBinary, a preview reports the required permission instead.
+func ResolveInstallTarget() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the keploy binary: %w", err)
 	}
+	return resolveInstallTarget(executable)
+}
 
-	// Determine the path based on the alias "keploy"
-	aliasPath := "/usr/local/bin/keploy" // Default path
+func resolveInstallTarget(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory, not a file", resolved)
+	}
+	return resolved, nil
+}
 
-	keployPath, err := exec.LookPath("keploy")
-	if err == nil && keployPath != "" {
-		aliasPath = keployPath
+// checkWritableDir checks that a file can be created in dir, the new binary is moved there.
+func checkWritableDir(dir string) error {
+	probe, err := os.CreateTemp(dir, ".keploy-update-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable, run the update with the required permissions: %w", dir, err)
+	}
+	if err := probe.Close(); err != nil {
+		return err
 	}
+	return os.Remove(probe.Name())
+}
 
-	// Check if the aliasPath is a valid path
-	_, err = os.Stat(aliasPath)
-	if os.IsNotExist(err) {
-		return fmt.Errorf("alias path %s does not exist", aliasPath)
+// UpdateBinary executes the update plan: it downloads the release archive and replaces
+// the installed keploy binary with it. Interrupted downloads are resumed from the
+// partial file and the result is verified against the release checksums.
+// A binary managed by a package manager is never replaced, its upgrade command is printed instead.
+func (t *Tools) UpdateBinary(ctx context.Context, plan UpdatePlan) error {
+	if managed, upgradeCmd := utils.IsPackageManaged(); managed {
+		fmt.Println("Keploy was installed with a package manager, run `" + upgradeCmd + "` to update it")
+		return errors.New("refusing to replace a keploy binary managed by a package manager")
+	}
+	// Fail before downloading anything when the binary can't be replaced.
+	if err := checkWritableDir(filepath.Dir(plan.TargetPath)); err != nil {
+		return err
+	}
+
+	verifySig := signatureVerificationEnabled(t.logger)
+	if verifySig && signingPublicKey == "" {
+		return errors.New("verify_signature is enabled but this keploy build has no signing key to verify the update with")
+	}
+
+	// The checksums file, the signature and the asset are fetched concurrently, the
+	// asset is verified once they are all there.
+	var expectedSum string
+	var signature []byte
+	tmpPath, err := partialDownloadPath(plan.Version, plan.AssetName)
+	if err != nil {
+		return err
+	}
+	err = fetchConcurrently(ctx, downloadConcurrency(t.logger),
+		func(ctx context.Context) error {
+			if !verifySig {
+				return nil
+			}
+			sig, err := fetchSignature(ctx, t.logger, plan.SignatureURL)
+			if err != nil {
+				return fmt.Errorf("refusing to install an update without a valid signature: failed to fetch %s: %w", plan.SignatureURL, err)
+			}
+			signature = sig
+			return nil
+		},
+		func(ctx context.Context) error {
+			sum, err := fetchChecksum(ctx, t.logger, plan.ChecksumURL, plan.AssetName)
+			if err != nil {
+				return fmt.Errorf("refusing to install an update which can't be verified: failed to fetch the checksum from %s: %w", plan.ChecksumURL, err)
+			}
+			expectedSum = sum
+			return nil
+		},
+		func(ctx context.Context) error {
+			return downloadWithResume(ctx, t.logger, plan.DownloadURL, tmpPath)
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if err := verifyDownload(t.logger, plan.DownloadURL, tmpPath, expectedSum); err != nil {
+		return err
 	}
+	if verifySig {
+		if err := verifySignature(tmpPath, signature, signingPublicKey); err != nil {
+			if rmErr := os.Remove(tmpPath); rmErr != nil {
+				utils.LogError(t.logger, rmErr, "failed to remove the unverified download")
+			}
+			return fmt.Errorf("refusing to install an update without a valid signature: %w", err)
+		}
+	}
+	defer func() {
+		if err := os.Remove(tmpPath); err != nil {
+			utils.LogError(t.logger, err, "failed to remove temporary file")
+		}
+	}()
 
-	// Check if the aliasPath is a directory
-	if fileInfo, err := os.Stat(aliasPath); err == nil && fileInfo.IsDir() {
-		return fmt.Errorf("alias path %s is a directory, not a file", aliasPath)
+	// Extract the tar.gz file
+	if err := extractTarGz(tmpPath, "/tmp"); err != nil {
+		return fmt.Errorf("failed to extract tar.gz file: %v", err)
 	}
 
+	aliasPath := plan.TargetPath
+
 	// Move the extracted binary to the alias path
 	if err := os.Rename("/tmp/keploy", aliasPath); err != nil {
 		return fmt.Errorf("failed to move keploy binary to %s: %v", aliasPath, err)
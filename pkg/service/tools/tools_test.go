@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.keploy.io/server/v2/utils"
+	"go.uber.org/zap"
+)
+
+// serveReleases serves latest as the latest keploy release, as well as any release
+// asked for by tag, and points the update endpoint of a temporary keploy config to it.
+func serveReleases(t *testing.T, latest string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tag := latest
+		if strings.Contains(r.URL.Path, "/tags/") {
+			tag = path.Base(r.URL.Path)
+		}
+		_, _ = fmt.Fprintf(w, `{"tag_name": %q}`, strings.TrimPrefix(tag, "v"))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("KEPLOY_HOME", t.TempDir())
+	if err := os.WriteFile(utils.KeployConfigPath(), []byte("update_url="+server.URL+"/latest\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func useVersion(t *testing.T, version string) {
+	t.Helper()
+	previous := utils.Version
+	utils.Version = version
+	t.Cleanup(func() { utils.Version = previous })
+}
+
+func TestUpdateAlreadyLatest(t *testing.T) {
+	useVersion(t, "1.2.0")
+	serveReleases(t, "v1.2.0")
+
+	// Nothing is downloaded, the download URL isn't served.
+	if err := NewTools(zap.NewNop(), nil).Update(context.Background()); err != nil {
+		t.Fatalf("Update() error = %v, want nil on the latest version", err)
+	}
+}
+
+func TestAssetFor(t *testing.T) {
+	tests := []struct {
+		goos, goarch, want string
+	}{
+		{"linux", "amd64", "keploy_linux_amd64.tar.gz"},
+		{"linux", "arm64", "keploy_linux_arm64.tar.gz"},
+		// macOS only has the universal binary, whichever slice runs, e.g. under Rosetta.
+		{"darwin", "amd64", "keploy_darwin_all.tar.gz"},
+		{"darwin", "arm64", "keploy_darwin_all.tar.gz"},
+	}
+	for _, tt := range tests {
+		got, err := AssetFor(tt.goos, tt.goarch)
+		if err != nil {
+			t.Fatalf("AssetFor(%s, %s) error = %v", tt.goos, tt.goarch, err)
+		}
+		if got != tt.want {
+			t.Errorf("AssetFor(%s, %s) = %q, want %q", tt.goos, tt.goarch, got, tt.want)
+		}
+	}
+	for _, platform := range [][2]string{{"windows", "amd64"}, {"linux", "386"}} {
+		if _, err := AssetFor(platform[0], platform[1]); err == nil {
+			t.Errorf("AssetFor(%s, %s) accepted an unsupported platform", platform[0], platform[1])
+		}
+	}
+}
+
+func TestPlanUpdate(t *testing.T) {
+	binary, err := ResolveInstallTarget()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := planUpdate("v1.2.0", "linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.TargetPath != binary {
+		t.Fatalf("TargetPath = %q, want the running binary %q", plan.TargetPath, binary)
+	}
+	if want := "write access to " + filepath.Dir(binary); plan.RequiredPermission != want {
+		t.Fatalf("RequiredPermission = %q, want %q", plan.RequiredPermission, want)
+	}
+	if !strings.HasSuffix(plan.DownloadURL, "/keploy_linux_amd64.tar.gz") || !strings.HasSuffix(plan.ChecksumURL, "/keploy_1.2.0_checksums.txt") {
+		t.Fatalf("plan = %+v, want the urls of the linux amd64 asset of v1.2.0", plan)
+	}
+}
+
+func TestPlanUpdateArch(t *testing.T) {
+	tests := []struct {
+		goos, goarch, want string
+	}{
+		{"linux", "amd64", "amd64"},
+		{"linux", "arm64", "arm64"},
+		// The universal binary is downloaded on macOS, whatever keploy runs as.
+		{"darwin", "amd64", "all"},
+		{"darwin", "arm64", "all"},
+	}
+	for _, tt := range tests {
+		plan, err := planUpdate("v1.2.0", tt.goos, tt.goarch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan.Arch != tt.want {
+			t.Errorf("planUpdate(%s/%s) Arch = %q, want the asset architecture %q", tt.goos, tt.goarch, plan.Arch, tt.want)
+		}
+	}
+}
+
+func TestUpdateToVersionAlreadyInstalled(t *testing.T) {
+	useVersion(t, "1.2.0")
+	serveReleases(t, "v1.3.0")
+
+	if err := NewTools(zap.NewNop(), nil).UpdateToVersion(context.Background(), "v1.2.0"); err != nil {
+		t.Fatalf("UpdateToVersion() error = %v, want nil on the installed version", err)
+	}
+}
+
+func TestUpdateBinaryPackageManaged(t *testing.T) {
+	t.Setenv("KEPLOY_HOME", t.TempDir())
+	if err := os.WriteFile(utils.KeployConfigPath(), []byte("install_method="+utils.InstallMethodApt+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(t.TempDir(), "keploy")
+	// Nothing is downloaded, the URLs are unreachable.
+	plan := UpdatePlan{DownloadURL: "http://127.0.0.1:0/keploy.tar.gz", TargetPath: target}
+	tools := &Tools{logger: zap.NewNop()}
+	if err := tools.UpdateBinary(context.Background(), plan); err == nil {
+		t.Fatal("UpdateBinary() replaced a binary managed by a package manager")
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("the target exists after a refused update, stat error = %v", err)
+	}
+}
+
+func TestResolveInstallTargetSymlink(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "versions", "keploy-v1.0.0")
+	if err := os.MkdirAll(filepath.Dir(real), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(real, []byte("keploy"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "keploy")
+	if err := os.Symlink(real, link); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := resolveInstallTarget(link)
+	if err != nil {
+		t.Fatalf("resolveInstallTarget() error = %v", err)
+	}
+	want, err := filepath.EvalSymlinks(real)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != want {
+		t.Fatalf("resolveInstallTarget() = %s, want the real binary %s", target, want)
+	}
+}
+
+func TestResolveInstallTargetDirectory(t *testing.T) {
+	if _, err := resolveInstallTarget(t.TempDir()); err == nil {
+		t.Fatal("resolveInstallTarget() accepted a directory")
+	}
+}
+
+func TestResolveInstallTargetReadOnlyDir(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to any directory")
+	}
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "keploy")
+	if err := os.WriteFile(binary, []byte("keploy"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0500); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(dir, 0700) })
+
+	// A dry run must work for a user who can't replace the binary.
+	if _, err := resolveInstallTarget(binary); err != nil {
+		t.Fatalf("resolveInstallTarget() error = %v, want the read-only binary resolved", err)
+	}
+	if err := checkWritableDir(dir); err == nil {
+		t.Fatal("checkWritableDir() accepted a read-only directory")
+	}
+}
+
+func TestNewToolsWithoutTelemetry(t *testing.T) {
+	// The auto-updater is created before the telemetry, sending must not panic.
+	NewTools(zap.NewNop(), nil).SendTelemetry("UpdateRun")
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Levels of the auto_update setting: the largest version bump installed without asking.
+const (
+	AutoUpdateOff   = "off"
+	AutoUpdatePatch = "patch"
+	AutoUpdateMinor = "minor"
+	AutoUpdateAll   = "all"
+)
+
+// AutoUpdater installs the given keploy version, e.g. with the tools service.
+type AutoUpdater func(ctx context.Context, version string) error
+
+var (
+	autoUpdaterMu sync.Mutex
+	autoUpdater   AutoUpdater
+)
+
+// SetAutoUpdater sets the function installing the updates allowed by auto_update.
+// Without one, passing nil, every update is offered with the prompt.
+func SetAutoUpdater(u AutoUpdater) {
+	autoUpdaterMu.Lock()
+	defer autoUpdaterMu.Unlock()
+	autoUpdater = u
+}
+
+func currentAutoUpdater() AutoUpdater {
+	autoUpdaterMu.Lock()
+	defer autoUpdaterMu.Unlock()
+	return autoUpdater
+}
+
+// autoUpdateLevel returns the auto_update setting, off by default and when invalid.
+func autoUpdateLevel(logger *zap.Logger) string {
+	value := GetString("auto_update")
+	switch level := strings.ToLower(strings.TrimSpace(value)); level {
+	case "":
+		return AutoUpdateOff
+	case AutoUpdateOff, AutoUpdatePatch, AutoUpdateMinor, AutoUpdateAll:
+		return level
+	default:
+		if logger != nil {
+			logger.Warn("invalid auto_update in the keploy config, expected off, patch, minor or all", zap.String("value", value))
+		}
+		return AutoUpdateOff
+	}
+}
+
+// autoUpdateAllowed tells whether the bump from current to latest is within the
+// auto_update level, e.g. v1.2.3 to v1.2.4 is a patch bump allowed by "patch".
+func autoUpdateAllowed(level, current, latest string) bool {
+	if level == AutoUpdateOff {
+		return false
+	}
+	from, err := ParseVersion(current)
+	if err != nil {
+		return false
+	}
+	to, err := ParseVersion(latest)
+	if err != nil || to.Compare(from) <= 0 {
+		return false
+	}
+	switch {
+	case to.Major != from.Major:
+		return level == AutoUpdateAll
+	case to.Minor != from.Minor:
+		return level == AutoUpdateMinor || level == AutoUpdateAll
+	default:
+		return true
+	}
+}
+
+// runAutoUpdate installs the version with the auto updater. The running process keeps
+// its version, the update takes effect on the next run.
+func runAutoUpdate(ctx context.Context, logger *zap.Logger, version string) {
+	updater := currentAutoUpdater()
+	if updater == nil {
+		return
+	}
+	logger.Info("automatically updating keploy, as allowed by auto_update", zap.String("version", DisplayVersion(version)))
+	if err := updater(ctx, version); err != nil {
+		LogError(logger, err, "failed to update keploy automatically, run `"+updateInstruction(installMethod())+"` to update")
+		return
+	}
+	logger.Info("keploy was updated, the new version is used from the next run", zap.String("version", DisplayVersion(version)))
+}
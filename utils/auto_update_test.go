@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestAutoUpdateAllowed(t *testing.T) {
+	tests := []struct {
+		level, current, latest string
+		want                   bool
+	}{
+		{AutoUpdateOff, "1.2.3", "1.2.4", false},
+		{AutoUpdatePatch, "1.2.3", "1.2.4", true},
+		{AutoUpdatePatch, "1.2.3", "1.3.0", false},
+		{AutoUpdateMinor, "1.2.3", "1.3.0", true},
+		{AutoUpdateMinor, "1.2.3", "2.0.0", false},
+		{AutoUpdateAll, "1.2.3", "2.0.0", true},
+		{AutoUpdateAll, "1.2.3", "1.2.3", false},
+		{AutoUpdateAll, "1.2.3", "1.2.2", false},
+		{AutoUpdateAll, "dev", "1.2.4", false},
+	}
+	for _, tt := range tests {
+		if got := autoUpdateAllowed(tt.level, tt.current, tt.latest); got != tt.want {
+			t.Errorf("autoUpdateAllowed(%q, %q, %q) = %v, want %v", tt.level, tt.current, tt.latest, got, tt.want)
+		}
+	}
+}
+
+func TestAutoUpdateLevel(t *testing.T) {
+	tests := map[string]string{
+		"":        AutoUpdateOff,
+		" Minor ": AutoUpdateMinor,
+		"all":     AutoUpdateAll,
+		"always":  AutoUpdateOff,
+	}
+	for value, want := range tests {
+		useKeployHome(t, "auto_update="+value+"\n")
+		if got := autoUpdateLevel(zap.NewNop()); got != want {
+			t.Errorf("autoUpdateLevel() with %q = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestCheckForUpdateAutoUpdates(t *testing.T) {
+	useVersion(t, "1.1.0")
+	server := serveRelease(t, GitHubRelease{TagName: "v1.1.1"})
+	useKeployHome(t, "update_pref=yes\nauto_update=patch\nupdate_url="+server.URL+"\n")
+	prompts := usePrompter(t, DecisionRemindLater)
+	var installed []string
+	SetAutoUpdater(func(_ context.Context, version string) error {
+		installed = append(installed, version)
+		return nil
+	})
+	t.Cleanup(func() { SetAutoUpdater(nil) })
+
+	if err := CheckForUpdate(context.Background(), zap.NewNop()); err != nil {
+		t.Fatalf("CheckForUpdate() error = %v", err)
+	}
+	if len(installed) != 1 || installed[0] != "v1.1.1" {
+		t.Fatalf("installed = %v, want v1.1.1 installed without a prompt", installed)
+	}
+	if len(*prompts) != 0 {
+		t.Fatalf("prompts = %v, want no prompt for an automatic update", *prompts)
+	}
+}
+
+func TestCheckForUpdateAutoUpdateNotAllowed(t *testing.T) {
+	useVersion(t, "1.1.0")
+	server := serveRelease(t, GitHubRelease{TagName: "v1.2.0"})
+	useKeployHome(t, "update_pref=yes\nauto_update=patch\nupdate_url="+server.URL+"\n")
+	prompts := usePrompter(t, DecisionRemindLater)
+	SetAutoUpdater(func(context.Context, string) error {
+		t.Error("a minor update was installed with auto_update=patch")
+		return nil
+	})
+	t.Cleanup(func() { SetAutoUpdater(nil) })
+
+	if err := CheckForUpdate(context.Background(), zap.NewNop()); err != nil {
+		t.Fatalf("CheckForUpdate() error = %v", err)
+	}
+	if len(*prompts) != 1 {
+		t.Fatalf("prompts = %v, want the update offered with the prompt", *prompts)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+func defaultBaseDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.Getenv("HOME")
+	}
+	return filepath.Join(home, ".keploy")
+}
+
+// MigrateLegacyBaseDir moves the keploy state left at a legacy location. Only windows
+// builds had one, it is a no-op elsewhere.
+func MigrateLegacyBaseDir(_ *zap.Logger) error {
+	return nil
+}
@@ -0,0 +1,126 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultBaseDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if got, want := defaultBaseDir(), filepath.Join(home, ".keploy"); got != want {
+		t.Fatalf("defaultBaseDir() = %q, want %q", got, want)
+	}
+	if err := MigrateLegacyBaseDir(nil); err != nil {
+		t.Fatalf("MigrateLegacyBaseDir() error = %v, want a no-op", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".keploy")); !os.IsNotExist(err) {
+		t.Fatal("MigrateLegacyBaseDir() created the keploy directory")
+	}
+}
+
+func TestResolveBaseDir(t *testing.T) {
+	parent, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(parent, "target")
+	if err := os.Mkdir(target, 0700); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(parent, "link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("KEPLOY_HOME", link+"/")
+	if got, err := ResolveBaseDir(); err != nil || got != target {
+		t.Fatalf("ResolveBaseDir() = %q, %v, want the symlink resolved to %q", got, err, target)
+	}
+	t.Setenv("KEPLOY_HOME", filepath.Join(parent, "missing"))
+	if got, err := ResolveBaseDir(); err != nil || got != filepath.Join(parent, "missing") {
+		t.Fatalf("ResolveBaseDir() = %q, %v, want a missing directory kept as is", got, err)
+	}
+	t.Setenv("KEPLOY_HOME", parent+"/target/../..")
+	if _, err := ResolveBaseDir(); err == nil {
+		t.Fatal("ResolveBaseDir() accepted a KEPLOY_HOME with \"..\"")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if got := BaseDir(); got != filepath.Join(home, ".keploy") {
+		t.Fatalf("BaseDir() = %q with an invalid KEPLOY_HOME, want the default", got)
+	}
+}
+
+func TestEnsureBaseDir(t *testing.T) {
+	t.Setenv("SUDO_UID", "")
+	dir := filepath.Join(t.TempDir(), "keploy")
+	t.Setenv("KEPLOY_HOME", dir)
+
+	got, err := EnsureBaseDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Fatalf("%s permissions = %v, want 0700", got, perm)
+	}
+
+	t.Setenv("KEPLOY_HOME", "../keploy")
+	if _, err := EnsureBaseDir(); err == nil {
+		t.Fatal("EnsureBaseDir() accepted an invalid KEPLOY_HOME")
+	}
+}
+
+func TestEnsureStateDirKeepsExistingMode(t *testing.T) {
+	t.Setenv("SUDO_UID", "")
+	existing := filepath.Join(t.TempDir(), "keploy")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(existing, "downloads")
+
+	if err := EnsureStateDir(created); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]os.FileMode{existing: 0755, created: 0700} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != want {
+			t.Errorf("%s permissions = %v, want %v", path, perm, want)
+		}
+	}
+}
+
+func TestEnsureStateDirUnderSudo(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner requires root")
+	}
+	t.Setenv("SUDO_UID", "65534")
+	t.Setenv("SUDO_GID", "65534")
+	parent := t.TempDir()
+	dir := filepath.Join(parent, ".keploy", "downloads")
+
+	if err := EnsureStateDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{dir, filepath.Dir(dir)} {
+		if uid, gid, _ := fileOwner(path); uid != 65534 || gid != 65534 {
+			t.Errorf("%s is owned by %d:%d, want the sudo user 65534:65534", path, uid, gid)
+		}
+	}
+	if uid, _, _ := fileOwner(parent); uid != 0 {
+		t.Errorf("the existing %s was given to %d, want it left to root", parent, uid)
+	}
+}
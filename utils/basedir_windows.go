@@ -0,0 +1,67 @@
+//go:build windows
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+func defaultBaseDir() string {
+	return windowsBaseDir(os.Getenv)
+}
+
+// windowsBaseDir resolves the keploy directory from the user profile, falling back to
+// the roaming application data directory. HOME is usually unset on windows, so it isn't used.
+func windowsBaseDir(getenv func(string) string) string {
+	if profile := getenv("USERPROFILE"); profile != "" {
+		return filepath.Join(profile, ".keploy")
+	}
+	if appData := getenv("APPDATA"); appData != "" {
+		return filepath.Join(appData, "keploy")
+	}
+	return filepath.Join(os.TempDir(), "keploy")
+}
+
+// MigrateLegacyBaseDir moves the keploy state that older versions wrote to \.keploy at
+// the root of the drive, because they resolved it from the empty HOME variable, into
+// BaseDir. Nothing is moved when BaseDir already exists or is set with KEPLOY_HOME.
+func MigrateLegacyBaseDir(logger *zap.Logger) error {
+	if os.Getenv("KEPLOY_HOME") != "" {
+		return nil
+	}
+	legacy, err := filepath.Abs(`\.keploy`)
+	if err != nil {
+		return err
+	}
+	target := BaseDir()
+	if legacy == target {
+		return nil
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		return nil
+	}
+	if _, err := os.Stat(target); err == nil {
+		if logger != nil {
+			logger.Warn("found keploy settings at a legacy location, they are ignored as "+target+" already exists",
+				zap.String("legacy", legacy))
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(legacy, target); err != nil {
+		return fmt.Errorf("failed to move the keploy settings from %s to %s: %w", legacy, target, err)
+	}
+	if logger != nil {
+		logger.Info("moved the keploy settings to "+target, zap.String("legacy", legacy))
+	}
+	// The settings may have been read before the migration.
+	invalidateGlobalConfig()
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWindowsBaseDir(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"user profile", map[string]string{"USERPROFILE": `C:\Users\dev`, "APPDATA": `C:\Users\dev\AppData\Roaming`}, filepath.Join(`C:\Users\dev`, ".keploy")},
+		{"app data", map[string]string{"APPDATA": `C:\Users\dev\AppData\Roaming`}, filepath.Join(`C:\Users\dev\AppData\Roaming`, "keploy")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			if got := windowsBaseDir(getenv); got != tt.want {
+				t.Fatalf("windowsBaseDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"runtime"
+	"strings"
+)
+
+// ExportForBugReport returns the keploy version, the platform and the effective
+// settings formatted for pasting into a GitHub issue. Sensitive values are redacted,
+// see FormatConfigTable, so the output is safe to share.
+func ExportForBugReport() (string, error) {
+	entries, err := EffectiveConfig()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("### Environment\n\n")
+	sb.WriteString("- Keploy version: " + DisplayVersion(Version) + "\n")
+	sb.WriteString("- OS/Arch: " + runtime.GOOS + "/" + runtime.GOARCH + "\n")
+	sb.WriteString("- Go version: " + runtime.Version() + "\n")
+	sb.WriteString("- Install method: " + installMethod() + "\n")
+	sb.WriteString("\n### Configuration\n\n```\n")
+	sb.WriteString(FormatConfigTable(entries))
+	sb.WriteString("```\n")
+	return sb.String(), nil
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestExportForBugReport(t *testing.T) {
+	useVersion(t, "1.2.3")
+	useKeployHome(t, "install_method=brew\napi_key=secret-value\nlog_level=debug\n")
+
+	report, err := ExportForBugReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"- Keploy version: v1.2.3\n",
+		"- OS/Arch: " + runtime.GOOS + "/" + runtime.GOARCH + "\n",
+		"- Install method: brew\n",
+		"log_level",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("ExportForBugReport() =\n%s\nwant it to contain %q", report, want)
+		}
+	}
+	if strings.Contains(report, "secret-value") {
+		t.Errorf("ExportForBugReport() =\n%s\nwant the api key redacted", report)
+	}
+}
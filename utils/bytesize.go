@@ -0,0 +1,21 @@
+package utils
+
+import "fmt"
+
+// ByteSize is a size in bytes, printed in human-readable units, e.g. "24.3 MB".
+type ByteSize int64
+
+func (b ByteSize) String() string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", int64(b))
+	}
+	value := float64(b) / unit
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f TB", value)
+}
@@ -0,0 +1,22 @@
+package utils
+
+import "testing"
+
+func TestByteSize(t *testing.T) {
+	tests := []struct {
+		size ByteSize
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{25480396, "24.3 MB"},
+		{3 << 30, "3.0 GB"},
+		{5 << 40, "5.0 TB"},
+	}
+	for _, tt := range tests {
+		if got := tt.size.String(); got != tt.want {
+			t.Errorf("ByteSize(%d).String() = %q, want %q", int64(tt.size), got, tt.want)
+		}
+	}
+}
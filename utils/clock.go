@@ -0,0 +1,27 @@
+package utils
+
+import "time"
+
+// Clock abstracts the passing of time so that time dependent code, such as periodic
+// checks, can be driven by a fake clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clock is the clock used by the package.
+var clock Clock = realClock{}
+
+// SetClock replaces the clock used by the package, a nil clock restores the real one.
+func SetClock(c Clock) {
+	if c == nil {
+		c = realClock{}
+	}
+	clock = c
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"sort"
+)
+
+// ConfigConflict is a setting set by several config sources.
+type ConfigConflict struct {
+	Key string
+	// Sources are the sources setting the key, from the lowest to the highest precedence.
+	Sources []string
+	// Effective is the value keploy runs with, the one of the last source.
+	Effective string
+}
+
+// DetectConfigConflicts returns the settings set by more than one source, sorted by
+// key, so that the user can find out which value wins. The sources are, from the
+// lowest to the highest precedence: the config (file, KEPLOY_CONFIG_B64 or
+// KEPLOY_CONFIG_URL), the active profile, and the KEPLOY_<KEY> environment variables.
+// Nothing is reported when the config can't be read.
+func DetectConfigConflicts() []ConfigConflict {
+	base, err := readBaseConfig(context.Background(), currentLogger())
+	if err != nil {
+		return nil
+	}
+
+	sources := map[string][]string{}
+	effective := map[string]string{}
+	set := func(key, value, source string) {
+		sources[key] = append(sources[key], source)
+		effective[key] = value
+	}
+
+	baseSource := configSource()
+	for key, value := range base {
+		set(key, value, baseSource)
+	}
+	if profile := activeProfile(base); profile != "" {
+		for key, value := range profileOverrides(base, profile) {
+			set(key, value, "profile "+profile)
+		}
+	}
+	for key := range configSchema {
+		name := ConfigEnvVar(key)
+		if value, ok := os.LookupEnv(name); ok {
+			set(key, value, "env "+name)
+		}
+	}
+
+	conflicts := []ConfigConflict{}
+	for key, keySources := range sources {
+		if len(keySources) > 1 {
+			conflicts = append(conflicts, ConfigConflict{Key: key, Sources: keySources, Effective: effective[key]})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Key < conflicts[j].Key })
+	return conflicts
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectConfigConflicts(t *testing.T) {
+	t.Setenv(ConfigEnvVar("log_level"), "error")
+	useKeployHome(t, "log_level=info\nactive_profile=prod\nprod.log_level=warn\nupdate_pref=yes\n")
+
+	path := KeployConfigPath()
+	want := []ConfigConflict{
+		{Key: "log_level", Sources: []string{path, "profile prod", "env KEPLOY_LOG_LEVEL"}, Effective: "error"},
+	}
+	if got := DetectConfigConflicts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("DetectConfigConflicts() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDetectConfigConflictsNone(t *testing.T) {
+	useKeployHome(t, "update_pref=yes\n")
+	if got := DetectConfigConflicts(); len(got) != 0 {
+		t.Fatalf("DetectConfigConflicts() = %+v, want no conflict", got)
+	}
+}
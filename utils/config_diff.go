@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// redactedValue replaces the value of sensitive settings in any output.
+const redactedValue = "***"
+
+// RedactConfigValue returns value, or a placeholder when key holds a credential.
+func RedactConfigValue(key, value string) string {
+	if isSensitiveKey(key) && value != "" {
+		return redactedValue
+	}
+	return value
+}
+
+// ConfigDiff compares two configs. added and removed hold the keys only present in
+// newConfig or oldConfig respectively, changed maps the keys present in both with a
+// different value to their new value. The values of sensitive keys are redacted.
+func ConfigDiff(oldConfig, newConfig map[string]string) (added, removed, changed map[string]string) {
+	added = map[string]string{}
+	removed = map[string]string{}
+	changed = map[string]string{}
+
+	for key, newValue := range newConfig {
+		oldValue, ok := oldConfig[key]
+		switch {
+		case !ok:
+			added[key] = RedactConfigValue(key, newValue)
+		case oldValue != newValue:
+			changed[key] = RedactConfigValue(key, newValue)
+		}
+	}
+	for key, oldValue := range oldConfig {
+		if _, ok := newConfig[key]; !ok {
+			removed[key] = RedactConfigValue(key, oldValue)
+		}
+	}
+	return added, removed, changed
+}
+
+// configPatchDeletePrefix marks the keys removed by a config patch.
+const configPatchDeletePrefix = "!"
+
+// ConfigPatch returns the minimal patch turning baseline into current: a "key=value"
+// line for every added or changed key and a "!key" line for every removed key, sorted
+// by key. Unlike ConfigDiff the values are not redacted, the patch is meant to be
+// applied on other machines with ApplyConfigPatch.
+func ConfigPatch(baseline, current map[string]string) ([]byte, error) {
+	lines := map[string]string{}
+	for key, value := range current {
+		if !configKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("invalid config key %q", key)
+		}
+		if old, ok := baseline[key]; !ok || old != value {
+			lines[key] = key + "=" + value
+		}
+	}
+	for key := range baseline {
+		if _, ok := current[key]; !ok {
+			lines[key] = configPatchDeletePrefix + key
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, key := range sortedKeys(lines) {
+		buf.WriteString(lines[key])
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// ApplyConfigPatch merges a patch produced by ConfigPatch into the keploy user
+// settings file. The patch is validated entirely before anything is written.
+func ApplyConfigPatch(patch []byte) error {
+	set := map[string]string{}
+	var deleted []string
+	for i, line := range strings.Split(string(patch), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if key, ok := strings.CutPrefix(trimmed, configPatchDeletePrefix); ok {
+			if !configKeyRegex.MatchString(key) {
+				return fmt.Errorf("invalid config patch line %d: %q", i+1, line)
+			}
+			deleted = append(deleted, key)
+			continue
+		}
+		key, value, ok := parseConfigLine(line)
+		if !ok {
+			return fmt.Errorf("invalid config patch line %d: %q", i+1, line)
+		}
+		if key != "" {
+			set[key] = value
+		}
+	}
+
+	config, err := readLocalKeployConfig(currentLogger())
+	if err != nil {
+		return err
+	}
+	for _, key := range deleted {
+		delete(config, key)
+	}
+	for key, value := range set {
+		config[key] = value
+	}
+	return WriteKeployConfig(currentLogger(), config)
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestConfigDiff(t *testing.T) {
+	oldConfig := map[string]string{"update_pref": "yes", "log_level": "info", "github_token": "old", "log_file": "keploy.log"}
+	newConfig := map[string]string{"update_pref": "yes", "log_level": "debug", "github_token": "new", "api_key": "key"}
+
+	added, removed, changed := ConfigDiff(oldConfig, newConfig)
+	if want := map[string]string{"api_key": redactedValue}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added = %v, want %v", added, want)
+	}
+	if want := map[string]string{"log_file": "keploy.log"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+	if want := map[string]string{"log_level": "debug", "github_token": redactedValue}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+}
+
+func TestRedactConfigValue(t *testing.T) {
+	if got := RedactConfigValue("github_token", ""); got != "" {
+		t.Errorf("RedactConfigValue() = %q, want an empty token kept empty", got)
+	}
+	if got := RedactConfigValue("log_level", "debug"); got != "debug" {
+		t.Errorf("RedactConfigValue() = %q, want the value of a non sensitive key", got)
+	}
+}
+
+func TestConfigPatch(t *testing.T) {
+	baseline := map[string]string{"log_level": "info", "update_pref": "yes", "github_token": "old"}
+	current := map[string]string{"log_level": "debug", "update_pref": "yes", "release_channel": "beta"}
+
+	patch, err := ConfigPatch(baseline, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "!github_token\nlog_level=debug\nrelease_channel=beta\n"
+	if string(patch) != want {
+		t.Fatalf("ConfigPatch() = %q, want %q", patch, want)
+	}
+	if _, err := ConfigPatch(nil, map[string]string{"bad key": "x"}); err == nil {
+		t.Fatal("ConfigPatch() accepted an invalid key")
+	}
+
+	useKeployHome(t, "log_level=info\nupdate_pref=yes\ngithub_token=old\n")
+	if err := ApplyConfigPatch(patch); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readLocalKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, current) {
+		t.Fatalf("config after ApplyConfigPatch() = %v, want %v", got, current)
+	}
+}
+
+func TestApplyConfigPatchInvalid(t *testing.T) {
+	useKeployHome(t, "log_level=info\n")
+	if err := ApplyConfigPatch([]byte("log_level=debug\nnot a line\n")); err == nil {
+		t.Fatal("ApplyConfigPatch() accepted an invalid line")
+	}
+	if got := GetString("log_level"); got != "info" {
+		t.Fatalf("log_level = %q, want nothing written from an invalid patch", got)
+	}
+}
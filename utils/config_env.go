@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+// configEnvOverridePrefix prefixes the environment variables overriding the settings,
+// e.g. KEPLOY_LOG_LEVEL overrides log_level.
+const configEnvOverridePrefix = "KEPLOY_"
+
+// ConfigEnvVar returns the name of the environment variable overriding a setting.
+func ConfigEnvVar(key string) string {
+	return configEnvOverridePrefix + strings.ToUpper(key)
+}
+
+// ApplyEnvOverrides overrides the known settings of config with the KEPLOY_<KEY>
+// environment variables which are set, and returns the source of each overridden
+// setting, e.g. "env KEPLOY_LOG_LEVEL". Unknown keys are left to the config file.
+func ApplyEnvOverrides(config map[string]string) map[string]string {
+	sources := map[string]string{}
+	for key := range configSchema {
+		name := ConfigEnvVar(key)
+		if value, ok := os.LookupEnv(name); ok {
+			config[key] = value
+			sources[key] = "env " + name
+		}
+	}
+	return sources
+}
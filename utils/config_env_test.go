@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestApplyEnvOverrides(t *testing.T) {
+	t.Setenv("KEPLOY_LOG_LEVEL", "debug")
+	t.Setenv("KEPLOY_NOT_A_SETTING", "1")
+	config := map[string]string{"log_level": "info", "update_pref": "yes"}
+
+	sources := ApplyEnvOverrides(config)
+	if config["log_level"] != "debug" || config["update_pref"] != "yes" {
+		t.Fatalf("config = %v, want only log_level overridden", config)
+	}
+	if _, ok := config["not_a_setting"]; ok {
+		t.Fatal("ApplyEnvOverrides() added an unknown setting")
+	}
+	if len(sources) != 1 || sources["log_level"] != "env KEPLOY_LOG_LEVEL" {
+		t.Fatalf("sources = %v, want the env var of log_level", sources)
+	}
+}
+
+func TestReadKeployConfigEnvOverride(t *testing.T) {
+	t.Setenv(ConfigEnvVar("log_level"), "warn")
+	useKeployHome(t, "log_level=info\n")
+
+	config, err := ReadKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config["log_level"] != "warn" {
+		t.Fatalf("log_level = %q, want KEPLOY_LOG_LEVEL to win over the config file", config["log_level"])
+	}
+	if GetString("log_level") != "warn" {
+		t.Fatalf("GetString(log_level) = %q, want the override", GetString("log_level"))
+	}
+}
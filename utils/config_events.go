@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"os"
+	"sync"
+)
+
+// ConfigEventType is the kind of config lifecycle event.
+type ConfigEventType int
+
+const (
+	// ConfigLoaded is sent when the keploy config is read.
+	ConfigLoaded ConfigEventType = iota
+	// ConfigReloaded is sent when GlobalConfig is refreshed from the config.
+	ConfigReloaded
+	// ConfigSaved is sent when the keploy config file is written.
+	ConfigSaved
+)
+
+func (t ConfigEventType) String() string {
+	switch t {
+	case ConfigLoaded:
+		return "loaded"
+	case ConfigReloaded:
+		return "reloaded"
+	case ConfigSaved:
+		return "saved"
+	default:
+		return "unknown"
+	}
+}
+
+// ConfigEvent describes a config lifecycle event. The key lists summarize the change
+// made by a reload or a save and are empty for a load.
+type ConfigEvent struct {
+	Type ConfigEventType
+	// Path is the config file, the URL the config is fetched from, or
+	// "env KEPLOY_CONFIG_B64" when the config comes from it.
+	Path    string
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// ConfigEventHandler receives the config lifecycle events. It is called synchronously
+// and must not read or write the config itself.
+type ConfigEventHandler func(event ConfigEvent)
+
+var (
+	configEventHandlerMu sync.Mutex
+	configEventHandler   ConfigEventHandler
+)
+
+// SetConfigEventHandler sets the handler of the config lifecycle events, passing nil
+// restores the default one which ignores them.
+func SetConfigEventHandler(h ConfigEventHandler) {
+	configEventHandlerMu.Lock()
+	defer configEventHandlerMu.Unlock()
+	configEventHandler = h
+}
+
+// emitConfigEvent sends an event to the handler. previous and current, when not nil,
+// fill the summary of the changed keys.
+func emitConfigEvent(eventType ConfigEventType, previous, current map[string]string) {
+	configEventHandlerMu.Lock()
+	handler := configEventHandler
+	configEventHandlerMu.Unlock()
+	if handler == nil {
+		return
+	}
+
+	event := ConfigEvent{Type: eventType, Path: configSource()}
+	if previous != nil && current != nil {
+		added, removed, changed := ConfigDiff(previous, current)
+		event.Added, event.Removed, event.Changed = sortedKeys(added), sortedKeys(removed), sortedKeys(changed)
+	}
+	handler(event)
+}
+
+// configSource returns where the keploy config is read from.
+func configSource() string {
+	if _, ok := os.LookupEnv(configEnvVar); ok {
+		return "env " + configEnvVar
+	}
+	if configURL := os.Getenv(configURLEnvVar); configURL != "" {
+		return redactURL(configURL)
+	}
+	return KeployConfigPath()
+}
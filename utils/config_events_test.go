@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestConfigEvents(t *testing.T) {
+	useKeployHome(t, "update_pref=yes\nlog_level=info\n")
+	var events []ConfigEvent
+	SetConfigEventHandler(func(event ConfigEvent) { events = append(events, event) })
+	t.Cleanup(func() { SetConfigEventHandler(nil) })
+
+	if _, err := ReadKeployConfig(zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteKeployConfig(zap.NewNop(), map[string]string{"update_pref": "no", "log_level": "info", "auto_update": "patch"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %+v, want a load and a save", events)
+	}
+	if events[0].Type != ConfigLoaded || events[0].Path != KeployConfigPath() {
+		t.Fatalf("first event = %+v, want the config file loaded", events[0])
+	}
+	saved := events[1]
+	if saved.Type != ConfigSaved || !reflect.DeepEqual(saved.Added, []string{"auto_update"}) || !reflect.DeepEqual(saved.Changed, []string{"update_pref"}) || len(saved.Removed) != 0 {
+		t.Fatalf("save event = %+v, want auto_update added and update_pref changed", saved)
+	}
+}
+
+func TestConfigEventSourceFromEnv(t *testing.T) {
+	t.Setenv(configEnvVar, "")
+	if got, want := configSource(), "env "+configEnvVar; got != want {
+		t.Fatalf("configSource() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ConfigValueType is the type of the value of a keploy setting.
+type ConfigValueType string
+
+// ConfigValueType constants
+const (
+	StringValue   ConfigValueType = "string"
+	BoolValue     ConfigValueType = "bool"
+	IntValue      ConfigValueType = "int"
+	DurationValue ConfigValueType = "duration"
+	PathValue     ConfigValueType = "path"
+	ListValue     ConfigValueType = "list"
+)
+
+// defaultListSeparator separates the items of list settings.
+const defaultListSeparator = ","
+
+// ConfigKeySpec describes a known keploy setting.
+type ConfigKeySpec struct {
+	Type ConfigValueType
+	// Sensitive settings hold credentials and are redacted from any output.
+	Sensitive bool
+	// Allowed lists the accepted values, compared case-insensitively. Any value of the
+	// type is accepted when it is empty.
+	Allowed []string
+	// Keywords are accepted besides the values of the type, e.g. "none" for a duration.
+	Keywords []string
+}
+
+// configSchema lists the known keploy settings.
+var configSchema = map[string]ConfigKeySpec{
+	"active_profile":          {Type: StringValue},
+	"api_key":                 {Type: StringValue, Sensitive: true},
+	"auto_update":             {Type: StringValue, Allowed: []string{AutoUpdateOff, AutoUpdatePatch, AutoUpdateMinor, AutoUpdateAll}},
+	"download_concurrency":    {Type: IntValue},
+	"github_token":            {Type: StringValue, Sensitive: true},
+	"ignore_headers":          {Type: ListValue},
+	"include":                 {Type: PathValue},
+	"install_method":          {Type: StringValue, Allowed: []string{InstallMethodScript, InstallMethodHomebrew, InstallMethodApt, InstallMethodDocker}},
+	"log_file":                {Type: PathValue},
+	"log_level":               {Type: StringValue, Allowed: []string{"debug", "info", "warn", "error"}},
+	"log_max_size_mb":         {Type: IntValue},
+	"log_output":              {Type: StringValue},
+	"minimum_version":         {Type: StringValue},
+	"network_retries":         {Type: IntValue},
+	"network_retry_backoff":   {Type: DurationValue},
+	"output_format":           {Type: StringValue, Allowed: []string{"text", "json"}},
+	"release_channel":         {Type: StringValue, Allowed: []string{"stable", "beta"}},
+	"shutdown_signals":        {Type: ListValue},
+	"shutdown_timeout":        {Type: DurationValue, Keywords: []string{"none"}},
+	"skipped_version":         {Type: ListValue},
+	"snooze_until":            {Type: StringValue},
+	"update_check_daily":      {Type: BoolValue},
+	"update_check_interval":   {Type: DurationValue},
+	"update_check_timeout":    {Type: DurationValue},
+	"update_pref":             {Type: StringValue, Allowed: []string{"yes", "no", "y", "n", "true", "false"}},
+	"update_prompt_default":   {Type: StringValue, Allowed: []string{"yes", "no", "y", "n", "true", "false"}},
+	"update_prompt_verbosity": {Type: StringValue, Allowed: []string{VerbosityQuiet, VerbosityNormal, VerbosityVerbose}},
+	"update_trace":            {Type: BoolValue},
+	"update_url":              {Type: StringValue},
+	"update_urls":             {Type: ListValue},
+	"verify_signature":        {Type: BoolValue},
+}
+
+// isSensitiveKey reports whether the setting holds a credential, including the
+// profile variants of the credential settings such as "prod.api_key".
+func isSensitiveKey(key string) bool {
+	if configSchema[key].Sensitive {
+		return true
+	}
+	_, name, ok := strings.Cut(key, ".")
+	return ok && configSchema[name].Sensitive
+}
+
+// configKeySpec returns the spec of a setting, including the profile variants of the
+// settings such as "prod.log_file". ok is false for unknown settings.
+func configKeySpec(key string) (spec ConfigKeySpec, ok bool) {
+	if spec, ok := configSchema[key]; ok {
+		return spec, true
+	}
+	if _, name, found := strings.Cut(key, "."); found {
+		spec, ok = configSchema[name]
+	}
+	return spec, ok
+}
+
+// configKeyType returns the type of a setting, unknown settings are strings. The
+// "<key>_file" variants of the settings, see loadSecretFiles, are paths.
+func configKeyType(key string) ConfigValueType {
+	if spec, ok := configKeySpec(key); ok {
+		return spec.Type
+	}
+	if _, ok := secretFileBase(key); ok {
+		return PathValue
+	}
+	return StringValue
+}
+
+// validateConfigValue checks a value against the type and the allowed values of its
+// setting. The error tells which values are accepted. An empty value is left unset.
+func validateConfigValue(key, value string) error {
+	spec, ok := configKeySpec(key)
+	if !ok {
+		return nil
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	if len(spec.Allowed) > 0 {
+		for _, allowed := range spec.Allowed {
+			if strings.EqualFold(value, allowed) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of %s, got %q", key, strings.Join(spec.Allowed, ","), value)
+	}
+	for _, keyword := range spec.Keywords {
+		if strings.EqualFold(value, keyword) {
+			return nil
+		}
+	}
+	switch spec.Type {
+	case BoolValue:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", key, value)
+		}
+	case IntValue:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s must be a whole number, got %q", key, value)
+		}
+	case DurationValue:
+		if _, err := time.ParseDuration(value); err != nil {
+			if len(spec.Keywords) > 0 {
+				return fmt.Errorf("%s must be a duration such as 30s or 24h, or one of %s, got %q", key, strings.Join(spec.Keywords, ","), value)
+			}
+			return fmt.Errorf("%s must be a duration such as 30s or 24h, got %q", key, value)
+		}
+	}
+	return nil
+}
+
+// GetString returns the value of a keploy setting, or an empty string when unset.
+func GetString(key string) string {
+	config, err := GlobalConfig()
+	if err != nil {
+		return ""
+	}
+	return config[key]
+}
+
+// GetIntInRange returns the value of an integer setting, or def when unset. A value
+// that is not a number gives def and an error, a value outside [min, max] is clamped to
+// the closest bound and returned with an error, so that callers can warn and go on.
+func GetIntInRange(key string, min, max, def int) (int, error) {
+	value := GetString(key)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return def, fmt.Errorf("invalid value %q for %s: expected a number", value, key)
+	}
+	switch {
+	case n < min:
+		return min, fmt.Errorf("invalid value %d for %s: must be at least %d", n, key, min)
+	case n > max:
+		return max, fmt.Errorf("invalid value %d for %s: must be at most %d", n, key, max)
+	}
+	return n, nil
+}
+
+// GetStringSlice returns the items of a list setting split on sep. The items are
+// trimmed and empty items are dropped. An empty sep uses the default "," separator.
+func GetStringSlice(key, sep string) []string {
+	return SplitConfigList(GetString(key), sep)
+}
+
+// SetStringSlice saves the items of a list setting in the keploy config, joined with sep.
+func SetStringSlice(logger *zap.Logger, key string, values []string, sep string) error {
+	config, err := readLocalKeployConfig(logger)
+	if err != nil {
+		return err
+	}
+	config[key] = JoinConfigList(values, sep)
+	return WriteKeployConfig(logger, config)
+}
+
+// SplitConfigList splits the value of a list setting, see GetStringSlice.
+func SplitConfigList(value, sep string) []string {
+	if sep == "" {
+		sep = defaultListSeparator
+	}
+	items := []string{}
+	for _, item := range strings.Split(value, sep) {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// JoinConfigList serializes the items of a list setting, the reverse of SplitConfigList.
+func JoinConfigList(values []string, sep string) string {
+	if sep == "" {
+		sep = defaultListSeparator
+	}
+	return strings.Join(values, sep)
+}
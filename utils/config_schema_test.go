@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSplitConfigList(t *testing.T) {
+	tests := []struct {
+		value, sep string
+		want       []string
+	}{
+		{value: "", want: []string{}},
+		{value: "a, b,,c ", want: []string{"a", "b", "c"}},
+		{value: "a;b, c", sep: ";", want: []string{"a", "b, c"}},
+	}
+	for _, tt := range tests {
+		got := SplitConfigList(tt.value, tt.sep)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitConfigList(%q, %q) = %q, want %q", tt.value, tt.sep, got, tt.want)
+		}
+		if joined := SplitConfigList(JoinConfigList(got, tt.sep), tt.sep); !reflect.DeepEqual(joined, got) {
+			t.Errorf("JoinConfigList(%q, %q) doesn't round trip: %q", got, tt.sep, joined)
+		}
+	}
+}
+
+func TestStringSliceSetting(t *testing.T) {
+	useKeployHome(t, "log_level=debug\n")
+	headers := []string{"Authorization", "Cookie"}
+	if err := SetStringSlice(zap.NewNop(), "ignore_headers", headers, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetStringSlice("ignore_headers", ""); !reflect.DeepEqual(got, headers) {
+		t.Fatalf("GetStringSlice() = %q, want %q", got, headers)
+	}
+	if got := GetString("log_level"); got != "debug" {
+		t.Fatalf("GetString() = %q, want the other settings kept", got)
+	}
+}
+
+func TestIsSensitiveKey(t *testing.T) {
+	for key, want := range map[string]bool{"github_token": true, "api_key": true, "log_level": false, "unknown": false} {
+		if got := isSensitiveKey(key); got != want {
+			t.Errorf("isSensitiveKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestGetIntInRange(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"", 2, false},
+		{"4", 4, false},
+		{" 8 ", 8, false},
+		{"0", 1, true},
+		{"20", 8, true},
+		{"many", 2, true},
+	}
+	for _, tt := range tests {
+		useKeployHome(t, "download_concurrency="+tt.value+"\n")
+		got, err := GetIntInRange("download_concurrency", 1, 8, 2)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("GetIntInRange() with %q = %d, %v, want %d, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestIsSensitiveKeyProfile(t *testing.T) {
+	if !isSensitiveKey("prod.api_key") {
+		t.Error("isSensitiveKey(\"prod.api_key\") = false, want the profile variant of a credential sensitive")
+	}
+	if isSensitiveKey("prod.log_level") {
+		t.Error("isSensitiveKey(\"prod.log_level\") = true")
+	}
+}
+
+func TestValidateConfigValue(t *testing.T) {
+	tests := []struct {
+		key, value string
+		wantErr    string
+	}{
+		{"auto_update", "Minor", ""},
+		{"auto_update", "always", `auto_update must be one of off,patch,minor,all, got "always"`},
+		{"prod.log_level", "trace", `prod.log_level must be one of debug,info,warn,error, got "trace"`},
+		{"update_trace", "maybe", `update_trace must be true or false, got "maybe"`},
+		{"network_retries", "3.5", `network_retries must be a whole number, got "3.5"`},
+		{"update_check_interval", "daily", `update_check_interval must be a duration such as 30s or 24h, got "daily"`},
+		{"update_check_interval", "", ""},
+		{"shutdown_timeout", "NONE", ""},
+		{"shutdown_timeout", "never", `shutdown_timeout must be a duration such as 30s or 24h, or one of none, got "never"`},
+		{"unknown_key", "anything", ""},
+	}
+	for _, tt := range tests {
+		err := validateConfigValue(tt.key, tt.value)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("validateConfigValue(%q, %q) error = %v, want nil", tt.key, tt.value, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("validateConfigValue(%q, %q) error = %v, want %q", tt.key, tt.value, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SnapshotConfig returns the content of the keploy config file, to restore it with
+// RestoreConfig if a multi-step change of the config fails midway. A nil snapshot
+// records that there is no config file.
+func SnapshotConfig() ([]byte, error) {
+	file, err := openKeployConfig(KeployConfigPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer SafeClose(currentLogger(), file, "the keploy config file")
+	return io.ReadAll(file)
+}
+
+// RestoreConfig puts the keploy config file back in the state recorded by
+// SnapshotConfig, atomically. Restoring a nil snapshot removes the file.
+func RestoreConfig(snapshot []byte) error {
+	path := KeployConfigPath()
+	previous, err := readLocalKeployConfig(currentLogger())
+	if err != nil {
+		previous = map[string]string{}
+	}
+
+	if snapshot == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		if err := EnsureStateDir(filepath.Dir(path)); err != nil {
+			return err
+		}
+		if err := writeConfigFileAtomic(currentLogger(), path, snapshot); err != nil {
+			return err
+		}
+	}
+
+	restored, err := ParseKeployConfig(bytes.NewReader(snapshot))
+	if err != nil {
+		restored = map[string]string{}
+	}
+	emitConfigEvent(ConfigSaved, previous, restored)
+	invalidateGlobalConfig()
+	return nil
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"os"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSnapshotAndRestoreConfig(t *testing.T) {
+	original := "# settings\nupdate_pref=no\n"
+	useKeployHome(t, original)
+
+	snapshot, err := SnapshotConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteKeployConfig(zap.NewNop(), map[string]string{"update_pref": "yes", "log_level": "debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreConfig(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(KeployConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != original {
+		t.Fatalf("restored config = %q, want %q", data, original)
+	}
+	if got := GetString("update_pref"); got != "no" {
+		t.Fatalf("update_pref = %q after the restore, want the restored value", got)
+	}
+}
+
+func TestRestoreConfigWithoutFile(t *testing.T) {
+	useKeployHome(t, "")
+
+	snapshot, err := SnapshotConfig()
+	if err != nil || snapshot != nil {
+		t.Fatalf("SnapshotConfig() = %q, %v, want a nil snapshot without a config file", snapshot, err)
+	}
+	if err := WriteKeployConfig(zap.NewNop(), map[string]string{"update_pref": "yes"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreConfig(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(KeployConfigPath()); !os.IsNotExist(err) {
+		t.Fatalf("the config file exists after restoring a nil snapshot: %v", err)
+	}
+	if got := GetString("update_pref"); got != "" {
+		t.Fatalf("update_pref = %q after the restore, want it unset", got)
+	}
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	conflictingKeysMu sync.Mutex
+	// conflictingKeys are the sets of settings which must not be set together.
+	conflictingKeys = [][]string{
+		// update_urls replaces update_url, which would be ignored.
+		{"update_url", "update_urls"},
+	}
+)
+
+// RegisterConflictingKeys registers settings which must not be set together, so that
+// ValidateConfig reports a config setting them instead of one of them being silently
+// ignored. A setting is given as "key", matching any value, or "key=value".
+func RegisterConflictingKeys(keys ...string) {
+	if len(keys) < 2 {
+		return
+	}
+	conflictingKeysMu.Lock()
+	defer conflictingKeysMu.Unlock()
+	conflictingKeys = append(conflictingKeys, append([]string(nil), keys...))
+}
+
+// ValidateConfig checks the settings, their values against the schema and the
+// conflicting settings, and returns an error describing every problem found, nil when
+// the config is valid.
+func ValidateConfig(config map[string]string) error {
+	var errs []error
+	for key, value := range config {
+		if err := validateConfigValue(key, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	conflictingKeysMu.Lock()
+	rules := append([][]string(nil), conflictingKeys...)
+	conflictingKeysMu.Unlock()
+	for _, keys := range rules {
+		matched := true
+		for _, key := range keys {
+			if !configKeyMatches(config, key) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			errs = append(errs, fmt.Errorf("%s can't be set together", strings.Join(keys, " and ")))
+		}
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+// configKeyMatches tells whether the config sets the setting given as "key" or "key=value".
+func configKeyMatches(config map[string]string, spec string) bool {
+	key, want, hasValue := strings.Cut(spec, "=")
+	value, ok := config[key]
+	if !ok {
+		return false
+	}
+	return !hasValue || strings.EqualFold(strings.TrimSpace(value), want)
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// useConflictingKeys restores the registered conflicting settings after the test.
+func useConflictingKeys(t *testing.T) {
+	t.Helper()
+	conflictingKeysMu.Lock()
+	previous := append([][]string(nil), conflictingKeys...)
+	conflictingKeysMu.Unlock()
+	t.Cleanup(func() {
+		conflictingKeysMu.Lock()
+		conflictingKeys = previous
+		conflictingKeysMu.Unlock()
+	})
+}
+
+func TestValidateConfig(t *testing.T) {
+	useConflictingKeys(t)
+	RegisterConflictingKeys("auto_update", "update_pref=no")
+	RegisterConflictingKeys("ignored")
+
+	tests := []struct {
+		name   string
+		config map[string]string
+		want   []string
+	}{
+		{"valid", map[string]string{"update_url": "https://example.com", "auto_update": "patch"}, nil},
+		{"conflicting keys", map[string]string{"update_url": "a", "update_urls": "b"}, []string{"update_url and update_urls can't be set together"}},
+		{"value matched", map[string]string{"auto_update": "patch", "update_pref": " NO "}, []string{"auto_update and update_pref=no can't be set together"}},
+		{"other value", map[string]string{"auto_update": "patch", "update_pref": "yes"}, nil},
+		{"invalid value", map[string]string{"log_level": "loud"}, []string{`log_level must be one of debug,info,warn,error, got "loud"`}},
+		{"every problem", map[string]string{"update_url": "a", "update_urls": "b", "auto_update": "all", "update_pref": "no"}, []string{
+			"auto_update and update_pref=no can't be set together",
+			"update_url and update_urls can't be set together",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(tt.config)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("ValidateConfig() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != strings.Join(tt.want, "\n") {
+				t.Fatalf("ValidateConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadKeployConfigWarnsInvalidConfig(t *testing.T) {
+	useKeployHome(t, "update_url=a\nupdate_urls=b\n")
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	config, err := ReadKeployConfig(zap.New(core))
+	if err != nil || config["update_urls"] != "b" {
+		t.Fatalf("ReadKeployConfig() = %v, %v, want the config loaded", config, err)
+	}
+	if logs.FilterMessage("invalid keploy config").Len() != 1 {
+		t.Fatalf("logs = %v, want the conflicting settings reported", logs.All())
+	}
+}
@@ -7,80 +7,625 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
+
+	"go.keploy.io/server/v2/config"
 	"go.uber.org/zap"
 )
 
-var cancel context.CancelFunc
+var (
+	// cancelMu guards cancel and cancelCause, which the signal handler and Fatal read
+	// from their own goroutines.
+	cancelMu sync.Mutex
+	cancel   context.CancelFunc
+	// cancelCause cancels the global context with a cause, it is set by NewCtx.
+	cancelCause context.CancelCauseFunc
+)
 
-func NewCtx() context.Context {
-	// Create a context that can be canceled
-	ctx, cancel := context.WithCancel(context.Background())
+var (
+	stopReasonMu sync.Mutex
+	// stopReason is the reason recorded when the global context was canceled.
+	stopReason string
+)
 
-	SetCancel(cancel)
-	// Set up a channel to listen for signals
-	sigs := make(chan os.Signal, 1)
-	// os.Interrupt is more portable than syscall.SIGINT
-	// there is no equivalent for syscall.SIGTERM in os.Signal
-	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+// globalLogger is the logger registered via SetLogger. It is used by the signal
+// handler so that lifecycle messages don't end up as raw prints on stdout.
+var globalLogger atomic.Pointer[zap.Logger]
 
-	// Start a goroutine that will cancel the context when a signal is received
-	go func() {
-		<-sigs
-		fmt.Println("Signal received, canceling context...")
-		cancel()
-	}()
+var (
+	preStartHooksMu sync.Mutex
+	// preStartHooks run at the start of the global context creation.
+	preStartHooks []func() error
+	// preStartHooksRun counts the pre-start hooks run, for the shutdown summary.
+	preStartHooksRun int
+)
+
+// RegisterPreStart registers fn to run at the start of NewCtx, before the signals are
+// wired, e.g. to apply GOMAXPROCS from the config. The hooks run in registration order
+// and the first error aborts the context creation.
+func RegisterPreStart(fn func() error) {
+	preStartHooksMu.Lock()
+	defer preStartHooksMu.Unlock()
+	preStartHooks = append(preStartHooks, fn)
+}
+
+func runPreStartHooks() error {
+	preStartHooksMu.Lock()
+	hooks := append([]func() error(nil), preStartHooks...)
+	preStartHooksMu.Unlock()
+
+	for _, hook := range hooks {
+		preStartHooksMu.Lock()
+		preStartHooksRun++
+		preStartHooksMu.Unlock()
+		if err := hook(); err != nil {
+			return fmt.Errorf("pre-start hook failed: %w", err)
+		}
+	}
+	return nil
+}
 
+func NewCtx() context.Context {
+	ctx, _ := NewCtxWithCancel()
 	return ctx
 }
-func CheckForUpdate(ctx context.Context) {
-	currentVersion := "v" + Version
-	logger := zap.NewExample()
 
-	releaseInfo, err := GetLatestGitHubRelease(ctx, logger)
-	latestVersion := releaseInfo.TagName
+// NewCtxChecked is NewCtxWithCancel returning the error of the pre-start hooks, see
+// RegisterPreStart, or of an invalid shutdown_signals setting. No context is created then.
+func NewCtxChecked() (context.Context, context.CancelFunc, error) {
+	if err := runPreStartHooks(); err != nil {
+		return nil, nil, err
+	}
+	signals, err := shutdownSignals()
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := newCtxWithCancel(signals)
+	return ctx, cancel, nil
+}
 
+// NewCtxWithCancel creates the global context, canceled on the shutdown signals
+// (SIGINT/SIGTERM by default), and returns it along with its cancel function so that
+// callers can defer it. Canceling the context also stops the signal handling goroutine.
+// When a pre-start hook fails or shutdown_signals is invalid, the returned context is
+// already canceled with the error as its cause, use NewCtxChecked to get it directly.
+func NewCtxWithCancel() (context.Context, context.CancelFunc) {
+	ctx, cancel, err := NewCtxChecked()
 	if err != nil {
-		fmt.Printf("failed to fetch latest GitHub release version: %v\n", err)
+		ctx, cancelWithCause := context.WithCancelCause(context.Background())
+		cancelWithCause(err)
+		return ctx, func() {}
+	}
+	return ctx, cancel
+}
+
+// NewCtxNoSignals creates the global context like NewCtxWithCancel but without
+// handling any signal, for applications embedding keploy which own the signal
+// handling. The host shuts keploy down with the returned cancel function or Stop.
+// The pre-start hooks are not run.
+func NewCtxNoSignals() (context.Context, context.CancelFunc) {
+	return newCtxWithCancel(nil)
+}
+
+// NewCtxWithTimeout creates the global context like NewCtxWithCancel, bounded by the
+// overall timeout of the command. When it expires, context.Cause reports "command
+// timeout of <timeout> exceeded", which wraps context.DeadlineExceeded, so that the logs
+// explain why the work was aborted, see LogErrorCtx.
+func NewCtxWithTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := NewCtxWithCancel()
+	cause := fmt.Errorf("command timeout of %s exceeded: %w", timeout, context.DeadlineExceeded)
+	ctx, cancelTimeout := context.WithTimeoutCause(ctx, timeout, cause)
+	return ctx, func() {
+		cancelTimeout()
+		cancel()
+	}
+}
+
+// shutdownSignalNames maps the names accepted in shutdown_signals to the signals.
+// os.Interrupt is more portable than syscall.SIGINT.
+var shutdownSignalNames = map[string]os.Signal{
+	"INT":  os.Interrupt,
+	"TERM": syscall.SIGTERM,
+	"QUIT": syscall.SIGQUIT,
+}
+
+// defaultShutdownSignals is the default value of shutdown_signals.
+const defaultShutdownSignals = "INT,TERM"
+
+// shutdownSignals returns the signals stopping keploy, set with the shutdown_signals
+// config key as a comma separated list of names such as "INT,TERM" or "SIGINT".
+func shutdownSignals() ([]os.Signal, error) {
+	value := GetString("shutdown_signals")
+	if strings.TrimSpace(value) == "" {
+		value = defaultShutdownSignals
 	}
+	return ParseSignals(value)
+}
 
-	if currentVersion != latestVersion {
-		fmt.Println("New version of Keploy is available:")
-		fmt.Printf(currentVersion + " ----> " + latestVersion + "\n")
-		fmt.Println("Run `keploy update` to update")
+// ParseSignals parses a comma separated list of signal names, with or without the
+// "SIG" prefix and in any case.
+func ParseSignals(list string) ([]os.Signal, error) {
+	var signals []os.Signal
+	for _, name := range SplitConfigList(list, ",") {
+		sig, ok := shutdownSignalNames[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+		if !ok {
+			return nil, fmt.Errorf("unknown signal %q in shutdown_signals: expected INT, TERM or QUIT", name)
+		}
+		signals = append(signals, sig)
+	}
+	if len(signals) == 0 {
+		return nil, errors.New("shutdown_signals must list at least one signal")
 	}
+	return signals, nil
 }
 
+func newCtxWithCancel(signals []os.Signal) (context.Context, context.CancelFunc) {
+	// Create a context that can be canceled. It carries the keploy config, which the
+	// command fills in place from its flags and the config file, see ConfigFrom.
+	ctx, cancelWithCause := context.WithCancelCause(ConfigInto(context.Background(), config.New()))
+	cancelWithStatus := func(cause error) {
+		markStopping()
+		cancelWithCause(cause)
+	}
+	cancel := func() { cancelWithStatus(nil) }
+
+	setCancelFuncs(cancel, cancelWithStatus)
+	setStartedAt(clock.Now())
+	setStatus(StatusRunning)
+	if len(signals) == 0 {
+		// signal.Notify without signals would relay every incoming signal.
+		return ctx, cancel
+	}
+	// Set up a channel to listen for signals
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, signals...)
+
+	// Start a goroutine that will cancel the context when a signal is received
+	go func() {
+		select {
+		case <-sigs:
+			if logger := currentLogger(); logger != nil {
+				logger.Info("Signal received, canceling context...", zap.String("reason_category", string(ReasonSignal)))
+			}
+			setStopReason("signal received")
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(sigs)
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// StopReason is the category of the reason keploy is stopped for. It keeps the stop
+// logs consistent, the details are given along with it.
+type StopReason string
+
+const (
+	// ReasonSignal is used when keploy received SIGINT or SIGTERM.
+	ReasonSignal StopReason = "signal"
+	// ReasonUserRequest is used when the user asked keploy to stop, e.g. through the API.
+	ReasonUserRequest StopReason = "user_request"
+	// ReasonFatalError is used when keploy hit an unrecoverable error.
+	ReasonFatalError StopReason = "fatal_error"
+	// ReasonCompleted is used when keploy is done, e.g. the record timer elapsed.
+	ReasonCompleted StopReason = "completed"
+	// ReasonRestart is used when keploy hands over to a new process, see GracefulRestart.
+	ReasonRestart StopReason = "restart"
+	// ReasonCustom is the category of the free-form reasons passed to Stop.
+	ReasonCustom StopReason = "custom"
+)
 
 // Stop requires a reason to stop the server.
 // this is to ensure that the server is not stopped accidentally.
 // and to trace back the stopper
 func Stop(logger *zap.Logger, reason string) error {
+	return StopWithReason(logger, ReasonCustom, reason)
+}
+
+// StopWithReason stops keploy for a typed reason, detail describes it further. Both are
+// logged, as the "reason_category" and "reason" fields, followed by the shutdown summary.
+// Status reports stopping until the work draining after the stop is done, which the
+// caller tells with MarkStopped; StopWithTimeout waits for the drain itself.
+func StopWithReason(logger *zap.Logger, reason StopReason, detail string) error {
+	detail, err := stop(logger, reason, detail)
+	if err != nil {
+		return err
+	}
+	logShutdownSummary(logger, detail, false)
+	syncLogger(logger)
+	return nil
+}
+
+// MarkStopped records that keploy finished stopping, once the work draining after the
+// global context was canceled is done, e.g. when the command returns.
+func MarkStopped() {
+	setStatus(StatusStopped)
+}
+
+// StopWithTimeout stops keploy like StopWithReason and waits, for at most timeout,
+// for done to be closed by the goroutines draining on shutdown, see WaitForShutdown.
+// Status reports stopped once they are done.
+// The shutdown_timeout setting overrides timeout: a duration, or "none" to wait for the
+// drain as long as it takes. The shutdown summary tells whether the drain timed out.
+func StopWithTimeout(logger *zap.Logger, reason StopReason, detail string, done <-chan struct{}, timeout time.Duration) error {
+	detail, err := stop(logger, reason, detail)
+	if err != nil {
+		return err
+	}
+	timeout, bounded := shutdownTimeout(logger, timeout)
+	if bounded {
+		err = WaitForShutdown(logger, done, timeout)
+	} else {
+		logger.Info("waiting for the shutdown to complete, shutdown_timeout is none")
+		<-done
+	}
+	logShutdownSummary(logger, detail, err != nil)
+	if err == nil {
+		// A drain which timed out is still running, keploy is stopped once it exits.
+		setStatus(StatusStopped)
+	}
+	syncLogger(logger)
+	return err
+}
+
+// stop validates the reason, logs it and cancels the global context. It returns the
+// detail logged, which defaults to the reason.
+func stop(logger *zap.Logger, reason StopReason, detail string) (string, error) {
 	// Stop the server.
 	if logger == nil {
-		return errors.New("logger is not set")
+		return "", errors.New("logger is not set")
 	}
+	cancel, _ := cancelFuncs()
 	if cancel == nil {
 		err := errors.New("cancel function is not set")
 		LogError(logger, err, "failed stopping keploy")
-		return err
+		return "", err
 	}
 
-	if reason == "" {
+	if reason == "" || (reason == ReasonCustom && detail == "") {
 		err := errors.New("cannot stop keploy without a reason")
 		LogError(logger, err, "failed stopping keploy")
-		return err
+		return "", err
+	}
+	if detail == "" {
+		detail = string(reason)
 	}
 
-	logger.Info("stopping Keploy", zap.String("reason", reason))
-	ExecCancel()
-	return nil
+	logger.Info("stopping Keploy", zap.String("reason_category", string(reason)), zap.String("reason", detail))
+	setStopReason(detail)
+	cancel()
+	return detail, nil
+}
+
+// ShutdownSummary describes how keploy stopped.
+type ShutdownSummary struct {
+	Reason string
+	// Runtime is the time elapsed since the global context was created.
+	Runtime time.Duration
+	// HooksRun is the number of pre-start hooks run, see RegisterPreStart.
+	HooksRun      int
+	DrainTimedOut bool
 }
 
+// LifecycleStatus is the lifecycle state of keploy.
+type LifecycleStatus int
+
+const (
+	// StatusStopped is the status once keploy stopped, and before it starts.
+	StatusStopped LifecycleStatus = iota
+	// StatusRunning is the status while the global context is live.
+	StatusRunning
+	// StatusStopping is the status once the global context is canceled, while the
+	// in-flight work drains.
+	StatusStopping
+)
+
+func (s LifecycleStatus) String() string {
+	switch s {
+	case StatusRunning:
+		return "running"
+	case StatusStopping:
+		return "stopping"
+	default:
+		return "stopped"
+	}
+}
+
+var (
+	lifecycleMu sync.Mutex
+	// status is the current lifecycle state, see Status.
+	status LifecycleStatus
+	// startedAt is when the global context was created.
+	startedAt time.Time
+	// lastShutdownSummary is the summary logged by the last stop.
+	lastShutdownSummary ShutdownSummary
+)
+
+// Status tells whether keploy is running, stopping or stopped, e.g. for the
+// health endpoints to report a shutdown in progress. It is stopping from the
+// cancellation of the global context until the drain completes, see MarkStopped.
+func Status() LifecycleStatus {
+	lifecycleMu.Lock()
+	defer lifecycleMu.Unlock()
+	return status
+}
+
+func setStatus(s LifecycleStatus) {
+	lifecycleMu.Lock()
+	defer lifecycleMu.Unlock()
+	status = s
+}
+
+// markStopping moves a running keploy to stopping, a stopped one stays stopped.
+func markStopping() {
+	lifecycleMu.Lock()
+	defer lifecycleMu.Unlock()
+	if status == StatusRunning {
+		status = StatusStopping
+	}
+}
+
+func setStartedAt(t time.Time) {
+	lifecycleMu.Lock()
+	defer lifecycleMu.Unlock()
+	startedAt = t
+}
+
+// LastShutdownSummary returns the summary logged when keploy was last stopped.
+func LastShutdownSummary() ShutdownSummary {
+	lifecycleMu.Lock()
+	defer lifecycleMu.Unlock()
+	return lastShutdownSummary
+}
+
+// logShutdownSummary logs, at info level, the reason keploy stopped for along with
+// how long it ran, so that a graceful stop ends with a readable recap.
+func logShutdownSummary(logger *zap.Logger, reason string, drainTimedOut bool) {
+	preStartHooksMu.Lock()
+	hooksRun := preStartHooksRun
+	preStartHooksMu.Unlock()
+
+	lifecycleMu.Lock()
+	summary := ShutdownSummary{Reason: reason, HooksRun: hooksRun, DrainTimedOut: drainTimedOut}
+	if !startedAt.IsZero() {
+		summary.Runtime = clock.Now().Sub(startedAt)
+	}
+	lastShutdownSummary = summary
+	lifecycleMu.Unlock()
+
+	logger.Info("shutdown summary",
+		zap.String("reason", summary.Reason),
+		zap.Duration("runtime", summary.Runtime.Round(time.Millisecond)),
+		zap.Int("hooks_run", summary.HooksRun),
+		zap.Bool("drain_timed_out", summary.DrainTimedOut))
+}
+
+// syncLogger flushes the buffered log entries so that the last lines logged before
+// exiting are not lost.
+func syncLogger(logger *zap.Logger) {
+	if logger == nil {
+		return
+	}
+	if err := logger.Sync(); err != nil && !isBenignSyncError(err) {
+		fmt.Fprintln(os.Stderr, "failed to flush the logs:", err)
+	}
+}
+
+// isBenignSyncError reports whether err only holds the errors returned when syncing a
+// terminal or a pipe, such as stdout, which can't be synced and don't need to be.
+func isBenignSyncError(err error) bool {
+	errs := []error{err}
+	if multi, ok := err.(interface{ Errors() []error }); ok {
+		errs = multi.Errors()
+	}
+	for _, err := range errs {
+		if !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTTY) && !errors.Is(err, syscall.EBADF) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fatal stops keploy because of an unrecoverable error. It cancels the global context
+// with err as its cause, which can be read back with context.Cause.
+// It is safe to call from any goroutine.
+func Fatal(err error) {
+	if err == nil {
+		err = errors.New("fatal error")
+	}
+	if logger := currentLogger(); logger != nil {
+		LogError(logger, err, "stopping Keploy due to a fatal error", zap.String("reason_category", string(ReasonFatalError)))
+	}
+	setStopReason("fatal error: " + err.Error())
+	cancel, cancelCause := cancelFuncs()
+	if cancelCause != nil {
+		cancelCause(err)
+	} else if cancel != nil {
+		cancel()
+	}
+	syncLogger(currentLogger())
+}
+
+// ExecCancel cancels the global context without a reason.
+//
+// Deprecated: use ExecCancelWithReason or StopWithReason, which record why keploy stopped.
 func ExecCancel() {
-	cancel()
+	warnDeprecated("ExecCancel", "ExecCancelWithReason or StopWithReason")
+	ExecCancelWithReason("unspecified")
+}
+
+// ExecCancelWithReason cancels the global context and records the reason, which can
+// be read back with LastStopReason, so that it is possible to trace why keploy stopped.
+func ExecCancelWithReason(reason string) {
+	setStopReason(reason)
+	if logger := currentLogger(); logger != nil {
+		logger.Debug("canceling the keploy context", zap.String("reason", reason))
+	}
+	if cancel, _ := cancelFuncs(); cancel != nil {
+		cancel()
+	}
+}
+
+// LastStopReason returns the reason recorded when the global context was last canceled.
+func LastStopReason() string {
+	stopReasonMu.Lock()
+	defer stopReasonMu.Unlock()
+	return stopReason
+}
+
+func setStopReason(reason string) {
+	stopReasonMu.Lock()
+	defer stopReasonMu.Unlock()
+	stopReason = reason
 }
 
+// SetCancel replaces the function canceling the global context.
+//
+// Deprecated: the global context and its cancel function are set up by NewCtxWithCancel,
+// NewCtxChecked or NewCtxNoSignals.
 func SetCancel(c context.CancelFunc) {
-	cancel = c
+	warnDeprecated("SetCancel", "NewCtxWithCancel, NewCtxChecked or NewCtxNoSignals")
+	setCancelFuncs(c, nil)
+}
+
+// setCancelFuncs sets the functions canceling the global context, without and with a
+// cause. Fatal falls back to the first one when the second is nil.
+func setCancelFuncs(c context.CancelFunc, withCause context.CancelCauseFunc) {
+	cancelMu.Lock()
+	defer cancelMu.Unlock()
+	cancel, cancelCause = c, withCause
+}
+
+func cancelFuncs() (context.CancelFunc, context.CancelCauseFunc) {
+	cancelMu.Lock()
+	defer cancelMu.Unlock()
+	return cancel, cancelCause
+}
+
+// deprecationWarned records the deprecated functions already reported.
+var deprecationWarned sync.Map
+
+// warnDeprecated logs, once per run, that the deprecated function was called and what
+// replaces it. Nothing is logged, and the warning is kept for later, without a logger.
+func warnDeprecated(name, replacement string) {
+	logger := currentLogger()
+	if logger == nil {
+		return
+	}
+	if _, warned := deprecationWarned.LoadOrStore(name, true); warned {
+		return
+	}
+	logger.Warn("utils."+name+" is deprecated and will be removed, use "+replacement+" instead", zap.String("function", name))
+}
+
+// SetLogger registers the logger used for lifecycle messages such as the
+// signal notification in NewCtx. The entries logged before, e.g. the warnings of the
+// config loaded at startup, are written to it.
+func SetLogger(l *zap.Logger) {
+	globalLogger.Store(l)
+	if l != nil {
+		flushPendingLogs(l)
+	}
+}
+
+// currentLogger returns the logger registered with SetLogger, nil when none is.
+func currentLogger() *zap.Logger {
+	return globalLogger.Load()
+}
+
+// shutdownTimeout returns how long to wait for the drain on shutdown: the
+// shutdown_timeout setting, or def when it is unset or invalid. bounded is false when
+// it is "none", to wait as long as the drain takes.
+func shutdownTimeout(logger *zap.Logger, def time.Duration) (timeout time.Duration, bounded bool) {
+	value := strings.TrimSpace(GetString("shutdown_timeout"))
+	if value == "" {
+		return def, true
+	}
+	if strings.EqualFold(value, "none") {
+		return 0, false
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		logger.Warn("invalid shutdown_timeout in the keploy config, expected a duration or none", zap.String("value", value), zap.Duration("default", def))
+		return def, true
+	}
+	return timeout, true
+}
+
+// shutdownCountdownInterval is how often WaitForShutdown logs the remaining time.
+var shutdownCountdownInterval = time.Second
+
+// WaitForShutdown waits for done to be closed, e.g. by the goroutines draining on
+// shutdown, for at most timeout. While waiting it logs the remaining time at regular
+// intervals so that the user can see keploy didn't hang. It returns an error when the
+// timeout elapses first.
+func WaitForShutdown(logger *zap.Logger, done <-chan struct{}, timeout time.Duration) error {
+	deadline := clock.Now().Add(timeout)
+	for {
+		remaining := deadline.Sub(clock.Now())
+		if remaining <= 0 {
+			return fmt.Errorf("shutdown did not complete within %s", timeout)
+		}
+		select {
+		case <-done:
+			return nil
+		case <-clock.After(min(shutdownCountdownInterval, remaining)):
+		}
+		if remaining := deadline.Sub(clock.Now()); remaining > 0 && logger != nil {
+			logger.Info(fmt.Sprintf("waiting for shutdown, %s remaining...", remaining.Round(time.Second)))
+		}
+	}
+}
+
+// ResetForTesting clears the lifecycle state left by NewCtx, SetCancel, SetLogger and
+// the stop functions, so that every test starts clean. Stop fails with "cancel function
+// is not set" until a new context is created. It must not be used outside of tests.
+func ResetForTesting() {
+	deprecationWarned.Range(func(key, _ any) bool {
+		deprecationWarned.Delete(key)
+		return true
+	})
+	setCancelFuncs(nil, nil)
+	globalLogger.Store(nil)
+	pendingLogsMu.Lock()
+	pendingLogs = nil
+	pendingLogsMu.Unlock()
+	setStopReason("")
+	preStartHooksMu.Lock()
+	preStartHooks = nil
+	preStartHooksRun = 0
+	preStartHooksMu.Unlock()
+	lifecycleMu.Lock()
+	status = StatusStopped
+	startedAt = time.Time{}
+	lastShutdownSummary = ShutdownSummary{}
+	lifecycleMu.Unlock()
+}
+
+type ctxKey string
+
+// configKey is the context key holding the keploy config.
+const configKey ctxKey = "config"
+
+// ConfigInto returns a copy of ctx carrying the keploy config, so that code deep in
+// the call tree can read it with ConfigFrom instead of having it threaded through.
+func ConfigInto(ctx context.Context, conf *config.Config) context.Context {
+	return context.WithValue(ctx, configKey, conf)
+}
+
+// ConfigFrom returns the keploy config carried by ctx, which the contexts created by
+// NewCtx and its variants do. When none was set, e.g. for a context which doesn't
+// derive from them, the default config is returned so that callers never have to deal
+// with a nil config, and the missing config is logged.
+func ConfigFrom(ctx context.Context) *config.Config {
+	if conf, ok := ctx.Value(configKey).(*config.Config); ok && conf != nil {
+		return conf
+	}
+	pendingLogger().Warn("no keploy config in the context, using the default config")
+	return config.New()
 }
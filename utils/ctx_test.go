@@ -0,0 +1,453 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFatalFromGoroutine(t *testing.T) {
+	SetLogger(zap.NewNop())
+	t.Cleanup(func() { SetLogger(nil) })
+	ctx, cancel := context.WithCancelCause(context.Background())
+	setCancelFuncs(func() { cancel(nil) }, cancel)
+	t.Cleanup(func() { setCancelFuncs(nil, nil) })
+	err := errors.New("proxy failed")
+
+	go Fatal(err)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the global context is not canceled after Fatal")
+	}
+	if cause := context.Cause(ctx); cause != err {
+		t.Fatalf("context.Cause() = %v, want the fatal error", cause)
+	}
+}
+
+func TestFatalWithoutCause(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	SetCancel(cancel)
+	t.Cleanup(func() { setCancelFuncs(nil, nil) })
+
+	Fatal(nil)
+	if ctx.Err() == nil {
+		t.Fatal("the context set with SetCancel is not canceled after Fatal")
+	}
+}
+
+func TestNewCtxWithCancel(t *testing.T) {
+	ctx, cancel := NewCtxWithCancel()
+	t.Cleanup(func() { setCancelFuncs(nil, nil) })
+
+	if ctx.Err() != nil {
+		t.Fatal("the context is done before being canceled")
+	}
+	cancel()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the context is not canceled by its cancel function")
+	}
+	if cause := context.Cause(ctx); cause != context.Canceled {
+		t.Fatalf("context.Cause() = %v, want context.Canceled", cause)
+	}
+}
+
+func TestExecCancelWithReason(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	SetCancel(cancel)
+	t.Cleanup(func() { setCancelFuncs(nil, nil) })
+
+	ExecCancelWithReason("test finished")
+	if ctx.Err() == nil {
+		t.Fatal("the global context is not canceled")
+	}
+	if got := LastStopReason(); got != "test finished" {
+		t.Fatalf("LastStopReason() = %q, want the reason passed to ExecCancelWithReason", got)
+	}
+
+	Fatal(errors.New("proxy failed"))
+	if got := LastStopReason(); got != "fatal error: proxy failed" {
+		t.Fatalf("LastStopReason() = %q, want the fatal error", got)
+	}
+}
+
+func TestConfigFromNewCtx(t *testing.T) {
+	ctx, cancel := NewCtxWithCancel()
+	t.Cleanup(func() {
+		cancel()
+		setCancelFuncs(nil, nil)
+	})
+
+	conf := ConfigFrom(ctx)
+	if conf == nil {
+		t.Fatal("ConfigFrom() = nil for the global context")
+	}
+	// The command fills the config in place, the handlers see the loaded values.
+	conf.Debug = true
+	if !ConfigFrom(ctx).Debug {
+		t.Fatal("ConfigFrom() returned another config than the one of the global context")
+	}
+}
+
+func TestConfigFromMissing(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	SetLogger(zap.New(core))
+	t.Cleanup(func() { SetLogger(nil) })
+
+	if ConfigFrom(context.Background()) == nil {
+		t.Fatal("ConfigFrom() = nil, want the default config")
+	}
+	if logs.Len() != 1 {
+		t.Fatalf("logs = %v, want the missing config logged", logs.All())
+	}
+}
+
+func TestResetForTesting(t *testing.T) {
+	_, cancel := NewCtxWithCancel()
+	t.Cleanup(cancel)
+	SetLogger(zap.NewNop())
+	ExecCancelWithReason("test finished")
+
+	ResetForTesting()
+	if c, withCause := cancelFuncs(); c != nil || withCause != nil {
+		t.Fatal("the cancel functions are kept after ResetForTesting")
+	}
+	if currentLogger() != nil {
+		t.Fatal("the logger is kept after ResetForTesting")
+	}
+	if got := LastStopReason(); got != "" {
+		t.Fatalf("LastStopReason() = %q after ResetForTesting, want none", got)
+	}
+}
+
+func TestStopWithReason(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	SetCancel(cancel)
+	t.Cleanup(ResetForTesting)
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	if err := StopWithReason(logger, ReasonCustom, ""); err == nil {
+		t.Fatal("StopWithReason() accepted a custom reason without details")
+	}
+	if err := StopWithReason(logger, ReasonCompleted, ""); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("the global context is not canceled")
+	}
+	if got := LastStopReason(); got != string(ReasonCompleted) {
+		t.Fatalf("LastStopReason() = %q, want the reason category without details", got)
+	}
+	fields := logs.FilterMessage("stopping Keploy").All()
+	if len(fields) != 1 || fields[0].ContextMap()["reason_category"] != "completed" {
+		t.Fatalf("logs = %v, want the stop logged with its reason category", logs.All())
+	}
+}
+
+func TestWaitForShutdown(t *testing.T) {
+	previous := shutdownCountdownInterval
+	shutdownCountdownInterval = 10 * time.Millisecond
+	t.Cleanup(func() { shutdownCountdownInterval = previous })
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	done := make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() { close(done) })
+	if err := WaitForShutdown(zap.New(core), done, time.Minute); err != nil {
+		t.Fatalf("WaitForShutdown() error = %v", err)
+	}
+	if logs.Len() == 0 {
+		t.Fatal("no countdown logged while waiting for the shutdown")
+	}
+
+	if err := WaitForShutdown(zap.NewNop(), make(chan struct{}), 30*time.Millisecond); err == nil {
+		t.Fatal("WaitForShutdown() succeeded although the shutdown never completed")
+	}
+}
+
+// failingSyncer is a log output whose Sync fails like a full disk.
+type failingSyncer struct{ bytes.Buffer }
+
+func (*failingSyncer) Sync() error { return errors.New("no space left on device") }
+
+func TestSyncLoggerFailureOnStderr(t *testing.T) {
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), &failingSyncer{}, zapcore.InfoLevel)
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderrReader, stderrWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdoutWriter, stderrWriter
+	t.Cleanup(func() { os.Stdout, os.Stderr = stdout, stderr })
+
+	syncLogger(zap.New(core))
+
+	os.Stdout, os.Stderr = stdout, stderr
+	for _, w := range []*os.File{stdoutWriter, stderrWriter} {
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	printed, err := io.ReadAll(stdoutReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(printed) != 0 {
+		t.Fatalf("stdout = %q, want nothing printed", printed)
+	}
+	reported, err := io.ReadAll(stderrReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(reported), "no space left on device") {
+		t.Fatalf("stderr = %q, want the sync failure", reported)
+	}
+}
+
+func TestPreStartHooks(t *testing.T) {
+	t.Cleanup(ResetForTesting)
+	var order []int
+	RegisterPreStart(func() error { order = append(order, 1); return nil })
+	RegisterPreStart(func() error { order = append(order, 2); return nil })
+
+	ctx, cancel, err := NewCtxChecked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if ctx == nil || len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Fatalf("hooks ran in order %v, want [1 2]", order)
+	}
+
+	failure := errors.New("invalid GOMAXPROCS")
+	RegisterPreStart(func() error { return failure })
+	if _, _, err := NewCtxChecked(); !errors.Is(err, failure) {
+		t.Fatalf("NewCtxChecked() error = %v, want the hook error", err)
+	}
+	ctx, _ = NewCtxWithCancel()
+	if !errors.Is(context.Cause(ctx), failure) {
+		t.Fatalf("NewCtxWithCancel() cause = %v, want a context canceled with the hook error", context.Cause(ctx))
+	}
+}
+
+func TestParseSignals(t *testing.T) {
+	signals, err := ParseSignals("int, SIGTERM,Quit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT}
+	if len(signals) != len(want) {
+		t.Fatalf("ParseSignals() = %v, want %v", signals, want)
+	}
+	for i := range want {
+		if signals[i] != want[i] {
+			t.Fatalf("ParseSignals() = %v, want %v", signals, want)
+		}
+	}
+	for _, invalid := range []string{"", " , ", "INT,HUP"} {
+		if _, err := ParseSignals(invalid); err == nil {
+			t.Errorf("ParseSignals(%q) succeeded", invalid)
+		}
+	}
+}
+
+func TestNewCtxInvalidShutdownSignals(t *testing.T) {
+	t.Cleanup(ResetForTesting)
+	useKeployHome(t, "shutdown_signals=KILL\n")
+
+	if _, _, err := NewCtxChecked(); err == nil {
+		t.Fatal("NewCtxChecked() succeeded with an invalid shutdown_signals")
+	}
+	ctx, _ := NewCtxWithCancel()
+	if context.Cause(ctx) == nil {
+		t.Fatal("NewCtxWithCancel() returned a live context with an invalid shutdown_signals")
+	}
+}
+
+func TestShutdownSummary(t *testing.T) {
+	t.Cleanup(ResetForTesting)
+	useKeployHome(t, "")
+	RegisterPreStart(func() error { return nil })
+	if _, _, err := NewCtxChecked(); err != nil {
+		t.Fatal(err)
+	}
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	if err := StopWithReason(zap.New(core), ReasonCompleted, ""); err != nil {
+		t.Fatal(err)
+	}
+	summary := LastShutdownSummary()
+	if summary.Reason != string(ReasonCompleted) || summary.HooksRun != 1 || summary.DrainTimedOut || summary.Runtime < 0 {
+		t.Fatalf("LastShutdownSummary() = %+v, want a completed run with one hook", summary)
+	}
+	entries := logs.FilterMessage("shutdown summary").All()
+	if len(entries) != 1 || entries[0].ContextMap()["reason"] != string(ReasonCompleted) {
+		t.Fatalf("logs = %v, want the shutdown summary logged once", logs.All())
+	}
+}
+
+func TestStopWithTimeoutDrainTimedOut(t *testing.T) {
+	t.Cleanup(ResetForTesting)
+	useKeployHome(t, "")
+	NewCtxNoSignals()
+
+	if err := StopWithTimeout(zap.NewNop(), ReasonCompleted, "", make(chan struct{}), 20*time.Millisecond); err == nil {
+		t.Fatal("StopWithTimeout() succeeded although the drain never completed")
+	}
+	if summary := LastShutdownSummary(); !summary.DrainTimedOut {
+		t.Fatalf("LastShutdownSummary() = %+v, want the drain timeout reported", summary)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	tests := []struct {
+		value       string
+		want        time.Duration
+		wantBounded bool
+	}{
+		{"", 5 * time.Second, true},
+		{"30s", 30 * time.Second, true},
+		{"None", 0, false},
+		{"soon", 5 * time.Second, true},
+		{"-1s", 5 * time.Second, true},
+	}
+	for _, tt := range tests {
+		useKeployHome(t, "shutdown_timeout="+tt.value+"\n")
+		got, bounded := shutdownTimeout(zap.NewNop(), 5*time.Second)
+		if got != tt.want || bounded != tt.wantBounded {
+			t.Errorf("shutdownTimeout() with %q = %v, %v, want %v, %v", tt.value, got, bounded, tt.want, tt.wantBounded)
+		}
+	}
+}
+
+func TestStopWithTimeoutNone(t *testing.T) {
+	t.Cleanup(ResetForTesting)
+	useKeployHome(t, "shutdown_timeout=none\n")
+	NewCtxNoSignals()
+	done := make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() { close(done) })
+
+	if err := StopWithTimeout(zap.NewNop(), ReasonCompleted, "", done, time.Millisecond); err != nil {
+		t.Fatalf("StopWithTimeout() error = %v, want the drain awaited without a deadline", err)
+	}
+	if summary := LastShutdownSummary(); summary.DrainTimedOut {
+		t.Fatalf("LastShutdownSummary() = %+v, want the drain completed", summary)
+	}
+}
+
+func TestDeprecatedFunctionsWarnOnce(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	SetLogger(zap.New(core))
+	t.Cleanup(ResetForTesting)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	SetCancel(cancel)
+	SetCancel(cancel)
+	ExecCancel()
+	if ctx.Err() == nil {
+		t.Fatal("the global context is not canceled by ExecCancel")
+	}
+	for _, name := range []string{"SetCancel", "ExecCancel"} {
+		if got := logs.FilterField(zap.String("function", name)).Len(); got != 1 {
+			t.Errorf("%s was reported %d times, want once: %v", name, got, logs.All())
+		}
+	}
+
+	ResetForTesting()
+	SetLogger(zap.New(core))
+	SetCancel(cancel)
+	if got := logs.FilterField(zap.String("function", "SetCancel")).Len(); got != 2 {
+		t.Errorf("SetCancel was reported %d times after ResetForTesting, want again", got)
+	}
+}
+
+// newTestCtx creates the global context without signals and resets the lifecycle
+// state once the test is done.
+func newTestCtx(t *testing.T) context.Context {
+	t.Helper()
+	ResetForTesting()
+	t.Cleanup(ResetForTesting)
+	ctx, cancel := NewCtxNoSignals()
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestStatusStopWithReason(t *testing.T) {
+	ResetForTesting()
+	if got := Status(); got != StatusStopped {
+		t.Fatalf("Status() before start = %v, want stopped", got)
+	}
+	ctx := newTestCtx(t)
+	if got := Status(); got != StatusRunning {
+		t.Fatalf("Status() after start = %v, want running", got)
+	}
+
+	if err := StopWithReason(zap.NewNop(), ReasonCompleted, "done"); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("the global context is not canceled after the stop")
+	}
+	if got := Status(); got != StatusStopping {
+		t.Fatalf("Status() while draining = %v, want stopping", got)
+	}
+
+	MarkStopped()
+	if got := Status(); got != StatusStopped {
+		t.Fatalf("Status() after the drain = %v, want stopped", got)
+	}
+}
+
+func TestStatusStopWithTimeout(t *testing.T) {
+	newTestCtx(t)
+	useKeployHome(t, "")
+	done := make(chan struct{})
+	stopped := make(chan error, 1)
+
+	go func() {
+		stopped <- StopWithTimeout(zap.NewNop(), ReasonCompleted, "done", done, time.Minute)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for Status() != StatusStopping {
+		if time.Now().After(deadline) {
+			t.Fatalf("Status() = %v, want stopping while the drain runs", Status())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(done)
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+	if got := Status(); got != StatusStopped {
+		t.Fatalf("Status() after the drain = %v, want stopped", got)
+	}
+}
+
+func TestStatusStopWithTimeoutElapsed(t *testing.T) {
+	newTestCtx(t)
+	useKeployHome(t, "")
+
+	if err := StopWithTimeout(zap.NewNop(), ReasonCompleted, "done", make(chan struct{}), 10*time.Millisecond); err == nil {
+		t.Fatal("StopWithTimeout() succeeded although the drain never completed")
+	}
+	if got := Status(); got != StatusStopping {
+		t.Fatalf("Status() after the drain timed out = %v, want stopping", got)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package utils
+
+import "errors"
+
+// freeDiskSpace is not implemented on windows, the disk space check is skipped.
+func freeDiskSpace(_ string) (uint64, error) {
+	return 0, errors.New("free disk space is not available on windows")
+}
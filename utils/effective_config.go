@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Sources of the effective settings.
+const (
+	SourceDefault = "default"
+	SourceInclude = "include"
+)
+
+// maxTableValueWidth is the width above which values are truncated in FormatConfigTable.
+const maxTableValueWidth = 48
+
+// EffectiveConfig returns the settings keploy runs with, sorted by key, along with
+// their source: the config file, an included file, an environment variable, or the
+// default value of a well-known setting.
+func EffectiveConfig() ([]ConfigEntry, error) {
+	config, err := GlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	sources := map[string]string{}
+	if _, ok := os.LookupEnv(configEnvVar); ok {
+		for key := range config {
+			sources[key] = "env " + configEnvVar
+		}
+	} else if configURL := os.Getenv(configURLEnvVar); configURL != "" {
+		for key := range config {
+			sources[key] = redactURL(configURL)
+		}
+	} else {
+		local, err := readLocalKeployConfig(currentLogger())
+		if err != nil {
+			return nil, err
+		}
+		for key := range config {
+			sources[key] = SourceInclude
+			if _, ok := local[key]; ok {
+				sources[key] = KeployConfigPath()
+			} else if path, ok := local[key+secretFileSuffix]; ok {
+				sources[key] = path
+			}
+		}
+	}
+
+	for key, source := range ApplyEnvOverrides(config) {
+		sources[key] = source
+	}
+
+	defaults := DefaultSettings()
+	updatePref := "no"
+	if defaults.UpdatePref {
+		updatePref = "yes"
+	}
+	for key, value := range map[string]string{
+		"update_pref":           updatePref,
+		"log_level":             defaults.LogLevel,
+		"release_channel":       defaults.ReleaseChannel,
+		"update_check_interval": defaults.UpdateCheckInterval.String(),
+	} {
+		if _, ok := config[key]; !ok {
+			config[key] = value
+			sources[key] = SourceDefault
+		}
+	}
+
+	entries := make([]ConfigEntry, 0, len(config))
+	for key, value := range config {
+		entries = append(entries, ConfigEntry{Key: key, Value: value, Source: sources[key]})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// FormatConfigTable renders the entries as an aligned table with the key, value and
+// source columns, e.g. for "keploy config list". Sensitive values are redacted and long
+// values are truncated so that the table stays readable.
+func FormatConfigTable(entries []ConfigEntry) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	_, _ = w.Write([]byte("KEY\tVALUE\tSOURCE\n"))
+	for _, entry := range entries {
+		value := RedactConfigValue(entry.Key, entry.Value)
+		if len([]rune(value)) > maxTableValueWidth {
+			value = string([]rune(value)[:maxTableValueWidth-3]) + "..."
+		}
+		// Tabs and newlines would break the alignment.
+		value = strings.NewReplacer("\t", " ", "\n", " ").Replace(value)
+		_, _ = w.Write([]byte(entry.Key + "\t" + value + "\t" + entry.Source + "\n"))
+	}
+	_ = w.Flush()
+	return sb.String()
+}
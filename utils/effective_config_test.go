@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	useKeployHome(t, "log_level=debug\ninclude=team.conf\n")
+	include := filepath.Join(filepath.Dir(KeployConfigPath()), "team.conf")
+	if err := os.WriteFile(include, []byte("release_channel=beta\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(ConfigEnvVar("update_pref"), "no")
+	if err := ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := EffectiveConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]ConfigEntry{}
+	for i, entry := range entries {
+		if i > 0 && entries[i-1].Key >= entry.Key {
+			t.Fatalf("EffectiveConfig() is not sorted by key: %q before %q", entries[i-1].Key, entry.Key)
+		}
+		got[entry.Key] = entry
+	}
+	want := map[string]ConfigEntry{
+		"log_level":             {Value: "debug", Source: KeployConfigPath()},
+		"release_channel":       {Value: "beta", Source: SourceInclude},
+		"update_pref":           {Value: "no", Source: "env " + ConfigEnvVar("update_pref")},
+		"update_check_interval": {Value: DefaultSettings().UpdateCheckInterval.String(), Source: SourceDefault},
+	}
+	for key, w := range want {
+		if g := got[key]; g.Value != w.Value || g.Source != w.Source {
+			t.Errorf("EffectiveConfig() %s = %q from %q, want %q from %q", key, g.Value, g.Source, w.Value, w.Source)
+		}
+	}
+}
+
+func TestFormatConfigTable(t *testing.T) {
+	table := FormatConfigTable([]ConfigEntry{
+		{Key: "api_key", Value: "secret-value", Source: SourceDefault},
+		{Key: "update_url", Value: "https://" + strings.Repeat("x", 60), Source: SourceInclude},
+	})
+	lines := strings.Split(strings.TrimSuffix(table, "\n"), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "KEY") {
+		t.Fatalf("FormatConfigTable() =\n%s\nwant a header and two rows", table)
+	}
+	if strings.Contains(table, "secret-value") {
+		t.Errorf("FormatConfigTable() =\n%s\nwant the token redacted", table)
+	}
+	if !strings.Contains(lines[2], "...") {
+		t.Errorf("FormatConfigTable() row = %q, want the long value truncated", lines[2])
+	}
+	// The columns are aligned: the sources start at the same offset.
+	if strings.Index(lines[1], SourceDefault) != strings.Index(lines[2], SourceInclude) {
+		t.Errorf("FormatConfigTable() =\n%s\nwant aligned columns", table)
+	}
+}
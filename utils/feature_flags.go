@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// featureKeyPrefix prefixes the config keys of the feature flags, e.g. feature.new_proxy.
+const featureKeyPrefix = "feature."
+
+// featureEnvVar returns the environment variable overriding a feature flag, e.g.
+// KEPLOY_FEATURE_NEW_PROXY for new_proxy.
+func featureEnvVar(name string) string {
+	return "KEPLOY_FEATURE_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// IsFeatureEnabled tells whether the experimental feature is enabled, with the
+// feature.<name>=true setting or the KEPLOY_FEATURE_<NAME>=true environment variable,
+// which wins over the setting. Features are off by default, and so is a flag with an
+// invalid value.
+func IsFeatureEnabled(name string) bool {
+	value, ok := os.LookupEnv(featureEnvVar(name))
+	if !ok {
+		value = GetString(featureKeyPrefix + name)
+	}
+	enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+	return err == nil && enabled
+}
@@ -0,0 +1,29 @@
+package utils
+
+import "testing"
+
+func TestIsFeatureEnabled(t *testing.T) {
+	useKeployHome(t, "feature.new_proxy=true\nfeature.fast-path=yes\nfeature.off=false\n")
+
+	if !IsFeatureEnabled("new_proxy") {
+		t.Error("new_proxy is disabled, want feature.new_proxy=true to enable it")
+	}
+	if IsFeatureEnabled("fast-path") {
+		t.Error("fast-path is enabled by the invalid value yes")
+	}
+	if IsFeatureEnabled("unset") {
+		t.Error("a feature without a flag is enabled")
+	}
+
+	t.Setenv("KEPLOY_FEATURE_OFF", "true")
+	t.Setenv("KEPLOY_FEATURE_NEW_PROXY", "false")
+	if !IsFeatureEnabled("off") || IsFeatureEnabled("new_proxy") {
+		t.Error("the KEPLOY_FEATURE_<NAME> environment variables don't win over the settings")
+	}
+}
+
+func TestFeatureEnvVar(t *testing.T) {
+	if got := featureEnvVar("fast-path.v2"); got != "KEPLOY_FEATURE_FAST_PATH_V2" {
+		t.Fatalf("featureEnvVar() = %q, want KEPLOY_FEATURE_FAST_PATH_V2", got)
+	}
+}
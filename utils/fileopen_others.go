@@ -0,0 +1,14 @@
+//go:build !windows
+
+package utils
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isTransientOpenError reports whether opening a file failed because it was
+// temporarily unavailable.
+func isTransientOpenError(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR)
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsTransientOpenError(t *testing.T) {
+	if !isTransientOpenError(&os.PathError{Op: "open", Path: "config", Err: syscall.EAGAIN}) {
+		t.Error("EAGAIN is not transient")
+	}
+	if !isTransientOpenError(fmt.Errorf("wrapped: %w", syscall.EINTR)) {
+		t.Error("a wrapped EINTR is not transient")
+	}
+	if isTransientOpenError(&os.PathError{Op: "open", Path: "config", Err: syscall.ENOENT}) {
+		t.Error("ENOENT is transient")
+	}
+}
+
+func TestOpenKeployConfigMissingFailsFast(t *testing.T) {
+	previous := configOpenBackoff
+	configOpenBackoff = time.Minute
+	t.Cleanup(func() { configOpenBackoff = previous })
+
+	start := time.Now()
+	if _, err := openKeployConfig(filepath.Join(t.TempDir(), "config")); !os.IsNotExist(err) {
+		t.Fatalf("openKeployConfig() error = %v, want a not exist error", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("openKeployConfig() retried a missing file")
+	}
+}
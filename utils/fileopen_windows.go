@@ -0,0 +1,19 @@
+//go:build windows
+
+package utils
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// isTransientOpenError reports whether opening a file failed because another process,
+// typically an antivirus or the search indexer, held it.
+func isTransientOpenError(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation) || errors.Is(err, syscall.EAGAIN)
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	httpClientMu sync.Mutex
+	// httpClient is the client set with SetHTTPClient, nil for the default one.
+	httpClient *http.Client
+)
+
+// SetHTTPClient sets the client used by all the keploy network operations, such as the
+// update check and the updater downloads, e.g. to use a custom CA pool, mTLS or to
+// instrument the requests. Passing nil restores the default client.
+func SetHTTPClient(c *http.Client) {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	httpClient = c
+}
+
+// HTTPClient returns the client for a network operation bounded by timeout, 0 meaning
+// no timeout, e.g. for large downloads bounded by their context instead. It is a copy
+// of the client set with SetHTTPClient, sharing its transport, with the timeout set.
+func HTTPClient(timeout time.Duration) *http.Client {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	if httpClient == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	client := *httpClient
+	// The timeout of the custom client is replaced too when there is none, it would
+	// otherwise cut the downloads bounded by their context.
+	client.Timeout = timeout
+	return &client
+}
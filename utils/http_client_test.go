@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientTimeout(t *testing.T) {
+	custom := &http.Client{Timeout: 5 * time.Second}
+	SetHTTPClient(custom)
+	t.Cleanup(func() { SetHTTPClient(nil) })
+
+	if got := HTTPClient(time.Minute).Timeout; got != time.Minute {
+		t.Fatalf("HTTPClient(time.Minute).Timeout = %s, want 1m0s", got)
+	}
+	if got := HTTPClient(0).Timeout; got != 0 {
+		t.Fatalf("HTTPClient(0).Timeout = %s, want no timeout", got)
+	}
+	if custom.Timeout != 5*time.Second {
+		t.Fatalf("the custom client was modified, its timeout is %s", custom.Timeout)
+	}
+}
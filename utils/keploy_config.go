@@ -0,0 +1,1125 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// keployConfigFile is the name of the keploy user settings file. It is stored in
+// the keploy home directory and holds simple "key=value" lines, "#" starts a comment.
+const keployConfigFile = "config"
+
+// BaseDir returns the directory holding all the keploy state: the user settings, the
+// installation id and any cache. It is $KEPLOY_HOME when set and ~/.keploy otherwise,
+// which allows relocating all the state, e.g. for sandboxing or testing. On windows the
+// default is resolved from USERPROFILE or APPDATA. The path is canonical, see
+// ResolveBaseDir; an invalid KEPLOY_HOME is ignored with a warning.
+func BaseDir() string {
+	dir, err := ResolveBaseDir()
+	if err != nil {
+		invalidBaseDirOnce.Do(func() {
+			pendingLogger().Warn("ignoring the invalid keploy home directory", zap.Error(err))
+		})
+		return filepath.Clean(defaultBaseDir())
+	}
+	return dir
+}
+
+// invalidBaseDirOnce limits the warning about an invalid KEPLOY_HOME to one per run.
+var invalidBaseDirOnce sync.Once
+
+// ResolveBaseDir returns the keploy directory as an absolute and clean path with its
+// symlinks resolved, so that the state paths joined to it can't escape it. A
+// KEPLOY_HOME holding a ".." element is rejected.
+func ResolveBaseDir() (string, error) {
+	dir, name := os.Getenv("KEPLOY_HOME"), "KEPLOY_HOME"
+	if dir == "" {
+		dir, name = defaultBaseDir(), "the default keploy home directory"
+	}
+	for _, elem := range strings.FieldsFunc(dir, func(r rune) bool { return r == '/' || r == filepath.Separator }) {
+		if elem == ".." {
+			return "", fmt.Errorf("%s %q must not contain \"..\"", name, dir)
+		}
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s %q: %w", name, dir, err)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	return abs, nil
+}
+
+// EnsureBaseDir validates the keploy directory, see ResolveBaseDir, and creates it,
+// private to the user, when it is missing. It runs at startup so that an invalid
+// KEPLOY_HOME stops keploy rather than spreading its state elsewhere.
+func EnsureBaseDir() (string, error) {
+	dir, err := ResolveBaseDir()
+	if err != nil {
+		return "", err
+	}
+	if err := EnsureStateDir(dir); err != nil {
+		return "", fmt.Errorf("failed to create the keploy home directory %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// EnsureStateDir creates dir and its missing parents private to the user, an existing
+// directory keeps its permissions. When keploy runs with sudo, the directories it
+// creates are given to the user who ran sudo, who could not use them otherwise once
+// back to running keploy without sudo.
+func EnsureStateDir(dir string) error {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); !os.IsNotExist(err) || d == filepath.Dir(d) {
+			break
+		}
+		missing = append(missing, d)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	uid, gid, ok := sudoOwner()
+	if !ok {
+		return nil
+	}
+	for _, d := range missing {
+		if err := restoreOwner(d, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DownloadDir is the directory of the keploy home holding the partial update downloads.
+const DownloadDir = "downloads"
+
+// StatePath returns the path of the named state file inside BaseDir.
+func StatePath(name string) string {
+	return filepath.Join(BaseDir(), name)
+}
+
+// KeployConfigPath returns the path of the keploy user settings file.
+func KeployConfigPath() string {
+	return StatePath(keployConfigFile)
+}
+
+// maxIncludeDepth limits how deep "include" directives can be nested.
+const maxIncludeDepth = 8
+
+// ReadKeployConfig reads the keploy user settings file. A missing file is not an
+// error, an empty config is returned instead.
+//
+// A config can reference a base config with an "include=/path/to/base" line. The
+// included file is loaded first and the keys of the including file override it.
+func ReadKeployConfig(logger *zap.Logger) (map[string]string, error) {
+	return ReadKeployConfigCtx(context.Background(), logger)
+}
+
+// ReadKeployConfigCtx is ReadKeployConfig bound to a context. When the context is
+// canceled, e.g. because keploy is shutting down, no file is read and ctx.Err() is returned.
+//
+// When KEPLOY_CONFIG_B64 is set, the config is read from it instead of the file, see
+// LoadConfigFromEnv, and when KEPLOY_CONFIG_URL is set it is fetched from the URL, see
+// LoadConfigFromURL. The KEPLOY_<KEY> environment variables override the settings read,
+// see ApplyEnvOverrides.
+func ReadKeployConfigCtx(ctx context.Context, logger *zap.Logger) (map[string]string, error) {
+	config, err := readKeployConfig(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+	emitConfigEvent(ConfigLoaded, nil, nil)
+	return config, nil
+}
+
+// readKeployConfig reads the config like ReadKeployConfigCtx without sending the
+// ConfigLoaded event, for the reloads which send ConfigReloaded instead.
+func readKeployConfig(ctx context.Context, logger *zap.Logger) (map[string]string, error) {
+	config, err := readBaseConfig(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+	config = applyProfile(logger, config)
+	if err := loadSecretFiles(config); err != nil {
+		return nil, err
+	}
+	ApplyEnvOverrides(config)
+	if err := ValidateConfig(config); err != nil && logger != nil {
+		logger.Warn("invalid keploy config", zap.Error(err))
+	}
+	return config, nil
+}
+
+// readBaseConfig reads the config from its source, before the profile and the
+// environment overrides are applied.
+func readBaseConfig(ctx context.Context, logger *zap.Logger) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, ok := os.LookupEnv(configEnvVar); ok {
+		return LoadConfigFromEnv(configEnvVar)
+	}
+	if configURL := os.Getenv(configURLEnvVar); configURL != "" {
+		return LoadConfigFromURL(ctx, configURL)
+	}
+	return readKeployConfigFile(ctx, logger, KeployConfigPath(), map[string]bool{}, 0)
+}
+
+// profileEnvVar selects the active profile, it wins over the active_profile key.
+const profileEnvVar = "KEPLOY_PROFILE"
+
+// applyProfile applies the active config profile. A profile is a set of keys prefixed
+// with its name, e.g. "prod.api_key=...", overriding the base keys when the profile is
+// active. A profile key overrides both forms of a secret setting, e.g.
+// "prod.api_key_file=..." replaces a base api_key. The profile is selected with
+// KEPLOY_PROFILE or the active_profile key; an unknown profile leaves the base keys
+// untouched.
+func applyProfile(logger *zap.Logger, config map[string]string) map[string]string {
+	profile := activeProfile(config)
+	if profile == "" {
+		return config
+	}
+
+	overrides := profileOverrides(config, profile)
+	if len(overrides) == 0 {
+		if logger != nil {
+			logger.Warn("unknown keploy config profile, using the base settings", zap.String("profile", profile))
+		}
+		return config
+	}
+	for key := range overrides {
+		name := key
+		if base, ok := secretFileBase(key); ok {
+			name = base
+		}
+		delete(config, name)
+		delete(config, name+secretFileSuffix)
+	}
+	for key, value := range overrides {
+		config[key] = value
+	}
+	return config
+}
+
+// activeProfile returns the name of the active profile, empty when none is selected.
+func activeProfile(config map[string]string) string {
+	if profile := os.Getenv(profileEnvVar); profile != "" {
+		return profile
+	}
+	return config["active_profile"]
+}
+
+// profileOverrides returns the keys set by the profile, without the profile prefix.
+func profileOverrides(config map[string]string, profile string) map[string]string {
+	prefix := profile + "."
+	overrides := map[string]string{}
+	for key, value := range config {
+		if name, ok := strings.CutPrefix(key, prefix); ok && name != "" {
+			overrides[name] = value
+		}
+	}
+	return overrides
+}
+
+// configEnvVar holds the whole keploy config encoded in base64, which is handy in CI
+// systems and Kubernetes secrets where injecting a file is harder than a variable.
+const configEnvVar = "KEPLOY_CONFIG_B64"
+
+// LoadConfigFromEnv parses the keploy config held base64-encoded in the varName
+// environment variable. Includes are not resolved, as there is no file to resolve
+// them from. An unset variable yields an empty config.
+func LoadConfigFromEnv(varName string) (map[string]string, error) {
+	encoded := strings.TrimSpace(os.Getenv(varName))
+	if encoded == "" {
+		return map[string]string{}, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keploy config in %s: not valid base64: %w", varName, err)
+	}
+	return ParseKeployConfig(bytes.NewReader(data))
+}
+
+func readKeployConfigFile(ctx context.Context, logger *zap.Logger, path string, visited map[string]bool, depth int) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("too many nested includes in the keploy config (max %d)", maxIncludeDepth)
+	}
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+	if visited[path] {
+		return nil, fmt.Errorf("circular include of %s in the keploy config", path)
+	}
+	visited[path] = true
+
+	file, err := openKeployConfig(path)
+	if err != nil {
+		// Only the top level config is optional, an include must point to an existing file.
+		if os.IsNotExist(err) && depth == 0 {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	defer SafeClose(logger, file, "the keploy config file")
+
+	config, err := parseConfigFile(logger, file, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the keploy config file %s: %w", path, err)
+	}
+
+	if info, err := file.Stat(); err == nil {
+		checkConfigPermissions(logger, path, info.Mode(), config)
+	}
+	resolveConfigPaths(config, filepath.Dir(path))
+
+	include, ok := config["include"]
+	if !ok {
+		return config, nil
+	}
+	delete(config, "include")
+
+	includePath, err := ExpandPath(include)
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(includePath) {
+		includePath = filepath.Join(filepath.Dir(path), includePath)
+	}
+	base, err := readKeployConfigFile(ctx, logger, includePath, visited, depth+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to include %s: %w", include, err)
+	}
+	for key, value := range config {
+		base[key] = value
+	}
+	return base, nil
+}
+
+// resolveConfigPaths makes the relative values of the path settings, such as log_file,
+// relative to dir, the directory of the config file they're read from, rather than to
+// the directory keploy is launched from. The include directive is resolved separately.
+func resolveConfigPaths(config map[string]string, dir string) {
+	for key, value := range config {
+		if value == "" || key == "include" || configKeyType(key) != PathValue {
+			continue
+		}
+		path, err := ExpandPath(value)
+		if err != nil {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		config[key] = path
+	}
+}
+
+// secretFileSuffix marks the settings read from a file, e.g. api_key_file=/run/secrets/key
+// sets api_key to the content of the file, so that the secret isn't written in the config.
+const secretFileSuffix = "_file"
+
+// loadSecretFiles replaces the "<key>_file" settings of the known settings with the
+// content of the file they reference, without the trailing newlines. Setting both
+// "<key>" and "<key>_file" is an error, as one of them would be ignored.
+func loadSecretFiles(config map[string]string) error {
+	var fileKeys []string
+	for key := range config {
+		if _, ok := secretFileBase(key); ok {
+			fileKeys = append(fileKeys, key)
+		}
+	}
+	sort.Strings(fileKeys)
+
+	for _, key := range fileKeys {
+		name, _ := secretFileBase(key)
+		if _, ok := config[name]; ok {
+			return fmt.Errorf("%s and %s can't both be set in the keploy config", name, key)
+		}
+		data, err := os.ReadFile(config[key])
+		if err != nil {
+			return fmt.Errorf("failed to read %s for %s: %w", config[key], key, err)
+		}
+		config[name] = strings.TrimRight(string(data), "\r\n")
+		delete(config, key)
+	}
+	return nil
+}
+
+// secretFileBase returns the setting read from the file referenced by key, e.g.
+// api_key for api_key_file. ok is false when key doesn't reference a secret file.
+func secretFileBase(key string) (string, bool) {
+	name, ok := strings.CutSuffix(key, secretFileSuffix)
+	if !ok {
+		return "", false
+	}
+	_, known := configKeySpec(name)
+	return name, known
+}
+
+// configOpenAttempts and configOpenBackoff bound the retries of a config open failing
+// with a transient error, e.g. while an antivirus or an indexer holds the file.
+var (
+	configOpenAttempts = 3
+	configOpenBackoff  = 50 * time.Millisecond
+)
+
+// defaultMaxConfigSize is the size above which a config file is rejected.
+const defaultMaxConfigSize = 1 << 20
+
+// maxConfigSizeEnvVar overrides the maximum size of a config file, in bytes.
+const maxConfigSizeEnvVar = "KEPLOY_CONFIG_MAX_SIZE"
+
+// ErrConfigTooLarge is returned when a config file is larger than the maximum size.
+var ErrConfigTooLarge = errors.New("the keploy config file is too large")
+
+// maxConfigSize returns the maximum size of a config file. It can't be a setting, as
+// it guards the read of the settings.
+func maxConfigSize() int64 {
+	if value := os.Getenv(maxConfigSizeEnvVar); value != "" {
+		if size, err := strconv.ParseInt(value, 10, 64); err == nil && size > 0 {
+			return size
+		}
+	}
+	return defaultMaxConfigSize
+}
+
+// openKeployConfig opens a config file, retrying with a growing backoff on transient
+// errors. Other errors, such as a missing file or a denied permission, fail immediately.
+// Files other than regular files and files larger than the maximum size, e.g. a
+// config symlinked to a huge file, are rejected before anything is read.
+func openKeployConfig(path string) (*os.File, error) {
+	var err error
+	for attempt := 1; attempt <= configOpenAttempts; attempt++ {
+		var file *os.File
+		file, err = os.Open(path)
+		if err == nil {
+			if err := checkConfigFileSize(file, path); err != nil {
+				return nil, err
+			}
+			return file, nil
+		}
+		if !isTransientOpenError(err) {
+			return nil, err
+		}
+		if attempt < configOpenAttempts {
+			time.Sleep(time.Duration(attempt) * configOpenBackoff)
+		}
+	}
+	return nil, err
+}
+
+// checkConfigFileSize closes the file and returns an error when it is not a regular
+// file or is larger than the maximum size.
+func checkConfigFileSize(file *os.File, path string) error {
+	info, err := file.Stat()
+	if err == nil && !info.Mode().IsRegular() {
+		err = fmt.Errorf("%s is not a regular file", path)
+	} else if max := maxConfigSize(); err == nil && info.Size() > max {
+		err = fmt.Errorf("%w: %s is %d bytes, the maximum is %d bytes (set %s to raise it)", ErrConfigTooLarge, path, info.Size(), max, maxConfigSizeEnvVar)
+	}
+	if err != nil {
+		SafeClose(currentLogger(), file, "the keploy config file")
+	}
+	return err
+}
+
+var (
+	globalConfigOnce sync.Once
+	globalConfigMu   sync.RWMutex
+	globalConfig     map[string]string
+	globalConfigErr  error
+
+	// globalConfigStale is set when keploy wrote the config, which is then read again
+	// by the next GlobalConfig, serialized by globalConfigReloadMu.
+	globalConfigStale    atomic.Bool
+	globalConfigReloadMu sync.Mutex
+)
+
+// GlobalConfig returns the keploy user settings, read once per run and shared by all
+// callers so they observe a consistent state. Use ReloadConfig to read the file again;
+// the changes written by keploy itself are picked up automatically.
+// The returned map is a copy and can be modified freely.
+func GlobalConfig() (map[string]string, error) {
+	globalConfigOnce.Do(func() { loadGlobalConfig(context.Background(), ReadKeployConfigCtx) })
+	if globalConfigStale.Load() {
+		globalConfigReloadMu.Lock()
+		if globalConfigStale.CompareAndSwap(true, false) {
+			loadGlobalConfig(context.Background(), ReadKeployConfigCtx)
+		}
+		globalConfigReloadMu.Unlock()
+	}
+
+	globalConfigMu.RLock()
+	defer globalConfigMu.RUnlock()
+	if globalConfigErr != nil {
+		return nil, globalConfigErr
+	}
+	config := make(map[string]string, len(globalConfig))
+	for key, value := range globalConfig {
+		config[key] = value
+	}
+	return config, nil
+}
+
+// ReloadConfig reads the keploy user settings file again and refreshes GlobalConfig.
+func ReloadConfig() error {
+	return ReloadConfigCtx(context.Background())
+}
+
+// ReloadConfigCtx is ReloadConfig bound to a context. A reload triggered while the
+// context is canceled is abandoned and the previously loaded config is kept.
+func ReloadConfigCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	globalConfigOnce.Do(func() {})
+	globalConfigStale.Store(false)
+	globalConfigMu.RLock()
+	previous := globalConfig
+	globalConfigMu.RUnlock()
+
+	loadGlobalConfig(ctx, readKeployConfig)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	globalConfigMu.RLock()
+	current, err := globalConfig, globalConfigErr
+	globalConfigMu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if previous == nil {
+		previous = map[string]string{}
+	}
+	emitConfigEvent(ConfigReloaded, previous, current)
+	return nil
+}
+
+// ReloadConfigLogged reloads the keploy user settings like ReloadConfig but logs the
+// outcome, with the keys that changed, for operators reloading a running keploy. When
+// the file can't be read, e.g. because an edit left it invalid, the previous config is
+// kept rather than replaced by the error.
+func ReloadConfigLogged(logger *zap.Logger) error {
+	previous, _ := GlobalConfig()
+	config, err := readKeployConfig(context.Background(), logger)
+	if err != nil {
+		if logger != nil {
+			logger.Error("config reload failed, keeping previous config", zap.Error(err))
+		}
+		return err
+	}
+
+	globalConfigMu.Lock()
+	globalConfig, globalConfigErr = config, nil
+	globalConfigMu.Unlock()
+	emitConfigEvent(ConfigReloaded, previous, config)
+
+	if logger != nil {
+		added, removed, changed := ConfigDiff(previous, config)
+		logger.Info("config reloaded successfully",
+			zap.Strings("added", sortedKeys(added)), zap.Strings("removed", sortedKeys(removed)), zap.Strings("changed", sortedKeys(changed)))
+	}
+	return nil
+}
+
+// WatchConfigReload reloads the keploy user settings with ReloadConfigLogged every time
+// keploy receives SIGHUP, until ctx is done.
+func WatchConfigReload(ctx context.Context, logger *zap.Logger) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigs:
+			_ = ReloadConfigLogged(logger)
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// invalidateGlobalConfig makes the next GlobalConfig read the config again, after
+// keploy changed it.
+func invalidateGlobalConfig() {
+	globalConfigStale.Store(true)
+}
+
+// loadGlobalConfig sets GlobalConfig to the config returned by read.
+func loadGlobalConfig(ctx context.Context, read func(context.Context, *zap.Logger) (map[string]string, error)) {
+	config, err := read(ctx, pendingLogger())
+	if ctx.Err() != nil {
+		return
+	}
+
+	globalConfigMu.Lock()
+	defer globalConfigMu.Unlock()
+	globalConfig, globalConfigErr = config, err
+}
+
+// readLocalKeployConfig reads the keploy user settings file without resolving includes.
+// Read-modify-write updates use it so that they keep the include directive and don't
+// copy the keys of the included file.
+func readLocalKeployConfig(logger *zap.Logger) (map[string]string, error) {
+	file, err := openKeployConfig(KeployConfigPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	defer SafeClose(logger, file, "the keploy config file")
+	return ParseKeployConfig(file)
+}
+
+// ParseKeployConfig parses "key=value" lines. Blank lines and lines starting with "#"
+// are skipped, as well as malformed lines.
+func ParseKeployConfig(r io.Reader) (map[string]string, error) {
+	entries, err := ParseKeployConfigEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	config := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		config[entry.Key] = entry.Value
+	}
+	return config, nil
+}
+
+// ConfigEntry is a setting of the keploy config along with the comment annotating it.
+type ConfigEntry struct {
+	Key   string
+	Value string
+	// Comment is the text of the "#" lines written right above the key, one line per
+	// comment line and without the "#" prefix. It is empty when the key has no comment.
+	Comment string
+	// Source tells where the value comes from, it is only set by EffectiveConfig.
+	Source string
+}
+
+// ParseKeployConfigEntries parses the config like ParseKeployConfig but keeps the file
+// order and the comments: a block of comment lines directly followed by a key is
+// attached to that key. A blank line between them detaches the block.
+func ParseKeployConfigEntries(r io.Reader) ([]ConfigEntry, error) {
+	entries, _, err := parseConfigEntries(r)
+	return entries, err
+}
+
+// ParseKeployConfigStrict parses the config like ParseKeployConfig but fails on the
+// first malformed line with a *ConfigParseError instead of skipping it.
+func ParseKeployConfigStrict(r io.Reader) (map[string]string, error) {
+	entries, parseErrs, err := parseConfigEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(parseErrs) > 0 {
+		return nil, parseErrs[0]
+	}
+	config := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		config[entry.Key] = entry.Value
+	}
+	return config, nil
+}
+
+// parseConfigEntries parses the config entries and returns the malformed lines apart.
+func parseConfigEntries(r io.Reader) ([]ConfigEntry, []*ConfigParseError, error) {
+	var entries []ConfigEntry
+	var parseErrs []*ConfigParseError
+	var comment []string
+	scanner := bufio.NewScanner(eintrReader{r: r})
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		key, value, reason := parseConfigLineReason(line)
+		switch {
+		case reason != "":
+			parseErrs = append(parseErrs, &ConfigParseError{Line: lineNum, Content: line, Reason: reason})
+			comment = nil
+		case key != "":
+			entries = append(entries, ConfigEntry{Key: key, Value: value, Comment: strings.Join(comment, "\n")})
+			comment = nil
+		case strings.HasPrefix(strings.TrimSpace(line), "#"):
+			text := strings.TrimPrefix(strings.TrimSpace(line), "#")
+			comment = append(comment, strings.TrimPrefix(text, " "))
+		default:
+			comment = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return entries, parseErrs, nil
+}
+
+// strictConfigEnvVar makes the malformed lines of the keploy config an error rather
+// than a warning when set to true.
+const strictConfigEnvVar = "KEPLOY_STRICT_CONFIG"
+
+// parseConfigFile parses a config file. Malformed lines are logged as warnings and
+// skipped, or fail the parsing with a *ConfigParseError in strict mode.
+func parseConfigFile(logger *zap.Logger, r io.Reader, path string) (map[string]string, error) {
+	entries, parseErrs, err := parseConfigEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(parseErrs) > 0 {
+		if strings.EqualFold(os.Getenv(strictConfigEnvVar), "true") {
+			return nil, parseErrs[0]
+		}
+		if logger != nil {
+			for _, parseErr := range parseErrs {
+				logger.Warn("ignoring a malformed line of the keploy config", zap.String("path", path), zap.Int("line", parseErr.Line), zap.String("reason", parseErr.Reason))
+			}
+		}
+	}
+	config := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		config[entry.Key] = entry.Value
+	}
+	return config, nil
+}
+
+// maxEINTRRetries bounds the retries of a read interrupted by a signal.
+const maxEINTRRetries = 5
+
+// eintrReader retries the reads interrupted by a signal (EINTR), which network file
+// systems can report, so that a config read isn't aborted by a concurrent signal.
+type eintrReader struct {
+	r io.Reader
+}
+
+func (e eintrReader) Read(p []byte) (int, error) {
+	for retry := 0; ; retry++ {
+		n, err := e.r.Read(p)
+		if err == nil || !errors.Is(err, syscall.EINTR) || retry >= maxEINTRRetries {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// ReadKeployConfigComments returns the comments attached to the keys of the keploy user
+// settings file, indexed by key. Keys without a comment are absent.
+func ReadKeployConfigComments(logger *zap.Logger) (map[string]string, error) {
+	entries, err := readLocalConfigEntries(logger)
+	if err != nil {
+		return nil, err
+	}
+	comments := map[string]string{}
+	for _, entry := range entries {
+		if entry.Comment != "" {
+			comments[entry.Key] = entry.Comment
+		}
+	}
+	return comments, nil
+}
+
+// readLocalConfigEntries reads the entries of the keploy user settings file in the file
+// order, without resolving includes. A missing file has no entries.
+func readLocalConfigEntries(logger *zap.Logger) ([]ConfigEntry, error) {
+	file, err := openKeployConfig(KeployConfigPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer SafeClose(logger, file, "the keploy config file")
+	return ParseKeployConfigEntries(file)
+}
+
+// SetConfigComment attaches a comment to a key of the keploy user settings file, it is
+// written above the key. An empty comment removes the existing one.
+func SetConfigComment(logger *zap.Logger, key, comment string) error {
+	config, err := readLocalKeployConfig(logger)
+	if err != nil {
+		return err
+	}
+	if _, ok := config[key]; !ok {
+		return fmt.Errorf("key %q is not set in the keploy config", key)
+	}
+	return WriteKeployConfigWithComments(logger, config, map[string]string{key: comment})
+}
+
+// configKeyRegex matches the valid setting names.
+var configKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// parseConfigLine parses a single config line. ok is false when the line is malformed;
+// blank and comment lines are valid and return an empty key.
+func parseConfigLine(line string) (key, value string, ok bool) {
+	key, value, reason := parseConfigLineReason(line)
+	return key, value, reason == ""
+}
+
+// parseConfigLineReason is parseConfigLine returning why a malformed line is rejected,
+// the reason is empty for a valid line.
+func parseConfigLineReason(line string) (key, value, reason string) {
+	if !utf8.ValidString(line) {
+		return "", "", "invalid UTF-8"
+	}
+	if strings.ContainsFunc(line, func(r rune) bool { return unicode.IsControl(r) && r != '\t' }) {
+		return "", "", "control character"
+	}
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", ""
+	}
+	key, value, found := strings.Cut(line, "=")
+	key = strings.TrimSpace(key)
+	if !found {
+		return "", "", "expected key=value"
+	}
+	if !configKeyRegex.MatchString(key) {
+		return "", "", fmt.Sprintf("invalid key %q", key)
+	}
+	return key, strings.TrimSpace(value), ""
+}
+
+// ConfigParseError tells which line of the keploy config is invalid and why.
+type ConfigParseError struct {
+	// Line is the 1-based number of the invalid line.
+	Line    int
+	Content string
+	Reason  string
+}
+
+func (e *ConfigParseError) Error() string {
+	return fmt.Sprintf("line %d: %s: %q", e.Line, e.Reason, e.Content)
+}
+
+// RepairConfig repairs a corrupted keploy config file, e.g. after a crash. When the file
+// holds malformed lines, it is backed up to "<config>.corrupt" and rewritten with the
+// lines that parsed cleanly only. A file without malformed lines is left untouched.
+func RepairConfig() error {
+	path := KeployConfigPath()
+	file, err := openKeployConfig(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer SafeClose(currentLogger(), file, "the keploy config file")
+
+	entries, parseErrs, err := parseConfigEntries(file)
+	if err != nil {
+		return err
+	}
+	if len(parseErrs) == 0 {
+		return nil
+	}
+	config := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		config[entry.Key] = entry.Value
+	}
+	dropped := make([]int, 0, len(parseErrs))
+	for _, parseErr := range parseErrs {
+		dropped = append(dropped, parseErr.Line)
+	}
+
+	backupPath := path + ".corrupt"
+	if err := backupConfigFile(file, backupPath); err != nil {
+		return fmt.Errorf("failed to back up the corrupted keploy config: %w", err)
+	}
+	logger := currentLogger()
+	if logger != nil {
+		logger.Warn("dropped malformed lines from the keploy config, the original file was backed up",
+			zap.Ints("lines", dropped), zap.String("backup", backupPath))
+	}
+	return WriteKeployConfig(logger, config)
+}
+
+// ErrExternalConfig is returned when writing the keploy config file while the config
+// is read from somewhere else: the change would silently be ignored.
+var ErrExternalConfig = errors.New("the keploy config file is not in use")
+
+// externalConfigError returns an ErrExternalConfig when the config is read from
+// KEPLOY_CONFIG_B64 or KEPLOY_CONFIG_URL instead of the keploy config file, nil otherwise.
+func externalConfigError() error {
+	if _, ok := os.LookupEnv(configEnvVar); ok {
+		return fmt.Errorf("%w: the config is read from %s, unset it to change the file", ErrExternalConfig, configEnvVar)
+	}
+	if os.Getenv(configURLEnvVar) != "" {
+		return fmt.Errorf("%w: the config is read from %s, unset it to change the file", ErrExternalConfig, configURLEnvVar)
+	}
+	return nil
+}
+
+// backupConfigFile copies the config file, read from its start, to backupPath.
+func backupConfigFile(file *os.File, backupPath string) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	backup, err := os.OpenFile(backupPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(backup, file); err != nil {
+		SafeClose(currentLogger(), backup, "the config backup")
+		return err
+	}
+	return backup.Close()
+}
+
+// WriteKeployConfig writes the settings to the keploy user settings file. The file is
+// written to a temporary file first and renamed, so a crash never leaves a partial config.
+// The keys already in the file keep their order and their comments, new keys are
+// appended.
+func WriteKeployConfig(logger *zap.Logger, config map[string]string) error {
+	return WriteKeployConfigWithComments(logger, config, nil)
+}
+
+// WriteKeployConfigWithComments is WriteKeployConfig with comments to attach to the keys,
+// written as "# comment" lines above them. They replace the existing comments of these
+// keys, an empty comment removes it.
+//
+// Nothing is written when the config isn't read from the file, see ErrExternalConfig.
+func WriteKeployConfigWithComments(logger *zap.Logger, config map[string]string, comments map[string]string) error {
+	if err := externalConfigError(); err != nil {
+		if logger != nil {
+			logger.Warn("the keploy config was not saved", zap.Error(err))
+		}
+		return err
+	}
+	path := KeployConfigPath()
+	if err := EnsureStateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	entries, err := readLocalConfigEntries(logger)
+	if err != nil {
+		return err
+	}
+	previous := make(map[string]string, len(entries))
+	existing := map[string]string{}
+	for _, entry := range entries {
+		previous[entry.Key] = entry.Value
+		if entry.Comment != "" {
+			existing[entry.Key] = entry.Comment
+		}
+	}
+	for key, comment := range comments {
+		existing[key] = comment
+	}
+
+	// The keys already in the file keep their place, the new ones are appended sorted.
+	keys := make([]string, 0, len(config))
+	placed := make(map[string]bool, len(config))
+	for _, entry := range entries {
+		if _, ok := config[entry.Key]; ok && !placed[entry.Key] {
+			keys = append(keys, entry.Key)
+			placed[entry.Key] = true
+		}
+	}
+	added := make([]string, 0, len(config)-len(keys))
+	for key := range config {
+		if !placed[key] {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	keys = append(keys, added...)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		if comment := existing[key]; comment != "" {
+			for _, line := range strings.Split(comment, "\n") {
+				sb.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+			}
+		}
+		sb.WriteString(key + "=" + config[key] + "\n")
+	}
+
+	if err := writeConfigFileAtomic(logger, path, []byte(sb.String())); err != nil {
+		return err
+	}
+	emitConfigEvent(ConfigSaved, previous, config)
+	invalidateGlobalConfig()
+	return nil
+}
+
+// writeConfigFileAtomic writes data to the config file at path through a temporary
+// file renamed over it, so a crash never leaves a partial config. The owner of the
+// file is kept.
+func writeConfigFileAtomic(logger *zap.Logger, path string, data []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), keployConfigFile+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Remove(tmpFile.Name()); err != nil && !os.IsNotExist(err) {
+			LogError(logger, err, "failed to remove the temporary config file")
+		}
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		SafeClose(logger, tmpFile, "the temporary config file")
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpFile.Name(), 0600); err != nil {
+		return err
+	}
+	// The rename gives the config to the user running keploy. When keploy runs under
+	// sudo, that would leave the config owned by root and lock its owner out of it.
+	uid, gid, hasOwner := fileOwner(path)
+	if !hasOwner {
+		uid, gid, hasOwner = fileOwner(filepath.Dir(path))
+	}
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return err
+	}
+	if hasOwner {
+		if err := restoreOwner(path, uid, gid); err != nil {
+			LogError(logger, err, "failed to restore the owner of the keploy config", zap.String("path", path))
+		}
+	}
+	return nil
+}
+
+// CanonicalConfigBytes serializes the config as "key=value" lines sorted by key. The
+// output only depends on the content of the config, which makes it suitable for
+// hashing or diffing configs between machines.
+func CanonicalConfigBytes(config map[string]string) []byte {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(config[key])
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// minFreeConfigSpace is the free disk space below which the keploy config is not
+// considered writable.
+const minFreeConfigSpace = 1 << 20
+
+// CanWriteConfig reports whether the keploy config can be written, so that commands
+// persisting state can warn up front rather than fail at the end. A missing keploy
+// directory is writable when it can be created. The free disk space is checked on a
+// best-effort basis. The returned error explains why the config is not writable.
+func CanWriteConfig() (bool, error) {
+	if err := externalConfigError(); err != nil {
+		return false, err
+	}
+	dir := BaseDir()
+	// Find the closest existing ancestor, it is where the write would happen.
+	existing := dir
+	for {
+		info, err := os.Stat(existing)
+		if err == nil {
+			if !info.IsDir() {
+				return false, fmt.Errorf("%s is not a directory", existing)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return false, fmt.Errorf("no existing parent directory for %s", dir)
+		}
+		existing = parent
+	}
+
+	probe, err := os.CreateTemp(existing, ".keploy-write-check-*")
+	if err != nil {
+		return false, fmt.Errorf("%s is not writable: %w", existing, err)
+	}
+	SafeClose(currentLogger(), probe, "the write check file")
+	if err := os.Remove(probe.Name()); err != nil {
+		LogError(currentLogger(), err, "failed to remove the write check file")
+	}
+
+	if free, err := freeDiskSpace(existing); err == nil && free < minFreeConfigSpace {
+		return false, fmt.Errorf("not enough disk space left in %s", existing)
+	}
+	return true, nil
+}
+
+// IsReadOnlyError reports whether err was caused by a missing write permission, a
+// read-only file system or a config not read from the file, see ErrExternalConfig.
+func IsReadOnlyError(err error) bool {
+	return errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EROFS) || errors.Is(err, ErrExternalConfig)
+}
+
+// hasSensitiveKeys reports whether the config holds any credentials.
+func hasSensitiveKeys(config map[string]string) bool {
+	for key, value := range config {
+		if isSensitiveKey(key) && value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// SensitiveConfigValues returns the non empty values of the credential settings.
+func SensitiveConfigValues(config map[string]string) []string {
+	var values []string
+	for key, value := range config {
+		if isSensitiveKey(key) && value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// checkConfigPermissions warns when a config holding credentials can be read by
+// other users. Windows doesn't use unix permission bits, so the check is skipped there.
+func checkConfigPermissions(logger *zap.Logger, path string, mode os.FileMode, config map[string]string) {
+	if runtime.GOOS == "windows" || logger == nil {
+		return
+	}
+	if mode.Perm()&0077 == 0 || !hasSensitiveKeys(config) {
+		return
+	}
+	logger.Warn("the keploy config file contains credentials but is readable by other users, restrict its permissions by running: chmod 600 "+path,
+		zap.String("path", path), zap.String("mode", mode.Perm().String()))
+}
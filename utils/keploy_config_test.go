@@ -0,0 +1,707 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestParseKeployConfig(t *testing.T) {
+	input := "# keploy settings\n\nupdate_pref = yes\ngithub_token=abc=def\nmalformed line\n"
+	config, err := ParseKeployConfig(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"update_pref": "yes", "github_token": "abc=def"}
+	if len(config) != len(want) {
+		t.Fatalf("ParseKeployConfig() = %v, want %v", config, want)
+	}
+	for key, value := range want {
+		if config[key] != value {
+			t.Errorf("config[%q] = %q, want %q", key, config[key], value)
+		}
+	}
+}
+
+func TestWriteKeployConfigRoundTrip(t *testing.T) {
+	t.Setenv("KEPLOY_HOME", t.TempDir())
+	config := map[string]string{"update_pref": "no", "github_token": "secret"}
+	if err := WriteKeployConfig(zap.NewNop(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(KeployConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("config file permissions = %v, want 0600", perm)
+	}
+	got, err := ReadKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range config {
+		if got[key] != value {
+			t.Errorf("config[%q] = %q, want %q", key, got[key], value)
+		}
+	}
+}
+
+func TestReadKeployConfigMissing(t *testing.T) {
+	t.Setenv("KEPLOY_HOME", t.TempDir())
+	config, err := ReadKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config) != 0 {
+		t.Fatalf("ReadKeployConfig() = %v, want an empty config", config)
+	}
+}
+
+func TestBaseDir(t *testing.T) {
+	t.Setenv("KEPLOY_HOME", "")
+	if got, want := BaseDir(), defaultBaseDir(); got != want {
+		t.Errorf("BaseDir() = %q, want the default %q", got, want)
+	}
+
+	dir := t.TempDir()
+	t.Setenv("KEPLOY_HOME", dir)
+	if got := BaseDir(); got != dir {
+		t.Errorf("BaseDir() = %q, want KEPLOY_HOME %q", got, dir)
+	}
+	if got, want := KeployConfigPath(), filepath.Join(dir, keployConfigFile); got != want {
+		t.Errorf("KeployConfigPath() = %q, want %q", got, want)
+	}
+}
+
+func TestReadKeployConfigWarnsOnExposedCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		mode     os.FileMode
+		wantWarn bool
+	}{
+		{"credentials readable by others", "github_token=secret\n", 0644, true},
+		{"credentials private", "github_token=secret\n", 0600, false},
+		{"no credentials", "update_pref=yes\n", 0644, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KEPLOY_HOME", t.TempDir())
+			path := KeployConfigPath()
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(tt.content), tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(path, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+
+			core, logs := observer.New(zapcore.WarnLevel)
+			if _, err := ReadKeployConfig(zap.New(core)); err != nil {
+				t.Fatal(err)
+			}
+			if got := logs.Len() > 0; got != tt.wantWarn {
+				t.Fatalf("warned = %v, want %v", got, tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestReadKeployConfigInclude(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("KEPLOY_HOME", dir)
+	if err := os.WriteFile(filepath.Join(dir, "base"), []byte("update_pref=no\nlog_level=debug\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(KeployConfigPath(), []byte("include=base\nupdate_pref=yes\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ReadKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config["update_pref"] != "yes" || config["log_level"] != "debug" {
+		t.Fatalf("ReadKeployConfig() = %v, want the included keys overridden by the including file", config)
+	}
+	if _, ok := config["include"]; ok {
+		t.Fatal("ReadKeployConfig() kept the include directive")
+	}
+
+	local, err := readLocalKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local["include"] != "base" || local["log_level"] != "" {
+		t.Fatalf("readLocalKeployConfig() = %v, want the file without its includes resolved", local)
+	}
+}
+
+func TestReadKeployConfigIncludeErrors(t *testing.T) {
+	tests := map[string]map[string]string{
+		"circular": {"config": "include=other\n", "other": "include=config\n"},
+		"missing":  {"config": "include=absent\n"},
+	}
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("KEPLOY_HOME", dir)
+			for file, content := range files {
+				if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := ReadKeployConfig(zap.NewNop()); err == nil {
+				t.Fatal("ReadKeployConfig() succeeded with an invalid include")
+			}
+		})
+	}
+}
+
+func TestGlobalConfigReload(t *testing.T) {
+	useKeployHome(t, "log_level=info\n")
+
+	config, err := GlobalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config["log_level"] = "modified"
+	if err := os.WriteFile(KeployConfigPath(), []byte("log_level=debug\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if config, _ := GlobalConfig(); config["log_level"] != "info" {
+		t.Fatalf("GlobalConfig() log_level = %q, want the cached value until the reload", config["log_level"])
+	}
+
+	if err := ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if config, _ := GlobalConfig(); config["log_level"] != "debug" {
+		t.Fatalf("GlobalConfig() log_level = %q after ReloadConfig, want debug", config["log_level"])
+	}
+
+	if err := WriteKeployConfig(zap.NewNop(), map[string]string{"log_level": "warn"}); err != nil {
+		t.Fatal(err)
+	}
+	if config, _ := GlobalConfig(); config["log_level"] != "warn" {
+		t.Fatalf("GlobalConfig() log_level = %q after WriteKeployConfig, want warn", config["log_level"])
+	}
+}
+
+func TestIsReadOnlyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&os.PathError{Op: "open", Path: "config", Err: syscall.EACCES}, true},
+		{&os.PathError{Op: "open", Path: "config", Err: syscall.EROFS}, true},
+		{fmt.Errorf("save: %w", os.ErrPermission), true},
+		{&os.PathError{Op: "open", Path: "config", Err: syscall.ENOSPC}, false},
+		{errors.New("other"), false},
+	}
+	for _, tt := range tests {
+		if got := IsReadOnlyError(tt.err); got != tt.want {
+			t.Errorf("IsReadOnlyError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWriteKeployConfigReadOnlyHome(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to any directory")
+	}
+	home := useKeployHome(t, "")
+	if err := os.Chmod(home, 0500); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(home, 0700) })
+
+	err := savePreference(zap.NewNop(), true)
+	if !IsReadOnlyError(err) {
+		t.Fatalf("savePreference() error = %v, want a read-only error", err)
+	}
+}
+
+func TestReloadConfigCtxCanceled(t *testing.T) {
+	useKeployHome(t, "log_level=info\n")
+	if err := os.WriteFile(KeployConfigPath(), []byte("log_level=debug\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := ReadKeployConfigCtx(ctx, zap.NewNop()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ReadKeployConfigCtx() error = %v, want context.Canceled", err)
+	}
+	if err := ReloadConfigCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ReloadConfigCtx() error = %v, want context.Canceled", err)
+	}
+	if config, _ := GlobalConfig(); config["log_level"] != "info" {
+		t.Fatalf("GlobalConfig() log_level = %q, want the config loaded before the canceled reload", config["log_level"])
+	}
+}
+
+func TestWriteConfigInvalidatesGlobalConfig(t *testing.T) {
+	useKeployHome(t, "log_level=info\n")
+	if config, _ := GlobalConfig(); config["log_level"] != "info" {
+		t.Fatal("the initial config was not loaded")
+	}
+
+	// The write succeeds even though the config written can't be loaded, that is
+	// only reported when the config is read.
+	if err := WriteKeployConfig(zap.NewNop(), map[string]string{"include": "missing"}); err != nil {
+		t.Fatalf("WriteKeployConfig() error = %v", err)
+	}
+	if _, err := GlobalConfig(); err == nil {
+		t.Fatal("GlobalConfig() kept the config read before the write")
+	}
+
+	if err := WriteKeployConfig(zap.NewNop(), map[string]string{"log_level": "debug"}); err != nil {
+		t.Fatalf("WriteKeployConfig() error = %v", err)
+	}
+	if config, _ := GlobalConfig(); config["log_level"] != "debug" {
+		t.Fatalf("log_level = %q after the write, want debug", config["log_level"])
+	}
+}
+
+func TestCanonicalConfigBytes(t *testing.T) {
+	a := CanonicalConfigBytes(map[string]string{"update_pref": "yes", "log_level": "debug"})
+	b := CanonicalConfigBytes(map[string]string{"log_level": "debug", "update_pref": "yes"})
+	if want := "log_level=debug\nupdate_pref=yes\n"; string(a) != want || string(b) != want {
+		t.Fatalf("CanonicalConfigBytes() = %q and %q, want %q for both", a, b, want)
+	}
+	if got := CanonicalConfigBytes(nil); len(got) != 0 {
+		t.Fatalf("CanonicalConfigBytes(nil) = %q, want nothing", got)
+	}
+}
+
+func TestRepairConfig(t *testing.T) {
+	corrupted := "update_pref=no\nbad line\nlog_level=debug\nkey\x00=value\n"
+	useKeployHome(t, corrupted)
+
+	if err := RepairConfig(); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := os.ReadFile(KeployConfigPath() + ".corrupt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(backup) != corrupted {
+		t.Fatalf("backup = %q, want the original config", backup)
+	}
+	config, err := ReadKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config) != 2 || config["update_pref"] != "no" || config["log_level"] != "debug" {
+		t.Fatalf("repaired config = %v, want the well formed lines only", config)
+	}
+}
+
+func TestRepairConfigCleanFile(t *testing.T) {
+	useKeployHome(t, "# settings\nupdate_pref=no\n")
+	if err := RepairConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(KeployConfigPath() + ".corrupt"); !os.IsNotExist(err) {
+		t.Fatal("RepairConfig() backed up a config without malformed lines")
+	}
+}
+
+func TestOpenKeployConfigTooLarge(t *testing.T) {
+	home := useKeployHome(t, "update_pref=no\nlog_level=debug\n")
+	t.Setenv(maxConfigSizeEnvVar, "16")
+
+	_, err := openKeployConfig(KeployConfigPath())
+	if !errors.Is(err, ErrConfigTooLarge) {
+		t.Fatalf("openKeployConfig() error = %v, want ErrConfigTooLarge", err)
+	}
+	if err := RepairConfig(); !errors.Is(err, ErrConfigTooLarge) {
+		t.Fatalf("RepairConfig() error = %v, want ErrConfigTooLarge", err)
+	}
+	if _, err := openKeployConfig(home); err == nil || !strings.Contains(err.Error(), "not a regular file") {
+		t.Fatalf("openKeployConfig(directory) error = %v, want a not a regular file error", err)
+	}
+}
+
+func TestMaxConfigSize(t *testing.T) {
+	for _, value := range []string{"", "0", "-1", "lots"} {
+		t.Setenv(maxConfigSizeEnvVar, value)
+		if got := maxConfigSize(); got != defaultMaxConfigSize {
+			t.Errorf("maxConfigSize() with %q = %d, want the default", value, got)
+		}
+	}
+	t.Setenv(maxConfigSizeEnvVar, "4096")
+	if got := maxConfigSize(); got != 4096 {
+		t.Errorf("maxConfigSize() = %d, want 4096", got)
+	}
+}
+
+func TestWriteConfigKeepsOrderAndComments(t *testing.T) {
+	home := useKeployHome(t, "# how chatty keploy is\nlog_level=info\nupdate_pref=yes\n\n# the API key\n# from the dashboard\napi_key=abc\n")
+	config, err := readLocalKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	config["update_pref"] = "no"
+	config["auto_update"] = "patch"
+	delete(config, "log_level")
+
+	if err := WriteKeployConfig(zap.NewNop(), config); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(home, keployConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "update_pref=no\n# the API key\n# from the dashboard\napi_key=abc\nauto_update=patch\n"
+	if string(data) != want {
+		t.Fatalf("config file =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestSetConfigComment(t *testing.T) {
+	useKeployHome(t, "# old\nlog_level=info\n")
+	if err := SetConfigComment(zap.NewNop(), "log_level", "how chatty keploy is"); err != nil {
+		t.Fatal(err)
+	}
+	comments, err := ReadKeployConfigComments(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := comments["log_level"]; got != "how chatty keploy is" {
+		t.Fatalf("comment = %q, want the new comment", got)
+	}
+	if err := SetConfigComment(zap.NewNop(), "update_pref", "unset"); err == nil {
+		t.Fatal("SetConfigComment() accepted a key which is not set")
+	}
+}
+
+// assertConfigWriteRefused checks that writing the config is refused, and reported,
+// without touching the config file in home.
+func assertConfigWriteRefused(t *testing.T, home string) {
+	t.Helper()
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	err := WriteKeployConfig(zap.New(core), map[string]string{"update_pref": "no"})
+	if !errors.Is(err, ErrExternalConfig) {
+		t.Fatalf("WriteKeployConfig() error = %v, want ErrExternalConfig", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, keployConfigFile)); !os.IsNotExist(err) {
+		t.Fatalf("the keploy config file was written although it is not read: %v", err)
+	}
+	if logs.FilterMessage("the keploy config was not saved").Len() != 1 {
+		t.Fatalf("logs = %v, want a warning about the refused write", logs.All())
+	}
+	if writable, _ := CanWriteConfig(); writable {
+		t.Fatal("CanWriteConfig() = true, want false")
+	}
+}
+
+func TestWriteConfigRefusedWithConfigB64(t *testing.T) {
+	home := useKeployHome(t, "")
+	t.Setenv(configEnvVar, base64.StdEncoding.EncodeToString([]byte("log_level=debug\n")))
+	assertConfigWriteRefused(t, home)
+}
+
+func TestReadKeployConfigFromEnv(t *testing.T) {
+	useKeployHome(t, "log_level=info\n")
+	t.Setenv(configEnvVar, base64.StdEncoding.EncodeToString([]byte("log_level=debug\nupdate_pref=no\n")))
+
+	config, err := ReadKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config["log_level"] != "debug" || config["update_pref"] != "no" {
+		t.Fatalf("config = %v, want the config of %s over the file", config, configEnvVar)
+	}
+
+	t.Setenv(configEnvVar, "not base64!")
+	if _, err := ReadKeployConfig(zap.NewNop()); err == nil {
+		t.Fatalf("ReadKeployConfig() accepted an invalid %s", configEnvVar)
+	}
+}
+
+func TestReloadConfigLogged(t *testing.T) {
+	useKeployHome(t, "log_level=info\nupdate_pref=yes\n")
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	if err := os.WriteFile(KeployConfigPath(), []byte("log_level=debug\nrelease_channel=beta\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadConfigLogged(logger); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := logs.FilterMessage("config reloaded successfully").All()
+	if len(reloaded) != 1 {
+		t.Fatalf("logs = %v, want the reload logged", logs.All())
+	}
+	fields := reloaded[0].ContextMap()
+	for key, want := range map[string]string{"added": "[release_channel]", "removed": "[update_pref]", "changed": "[log_level]"} {
+		if got := fmt.Sprint(fields[key]); got != want {
+			t.Errorf("%s = %s, want %s", key, got, want)
+		}
+	}
+
+	// An invalid file keeps the previous config.
+	if err := os.WriteFile(KeployConfigPath(), []byte("include=missing\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadConfigLogged(logger); err == nil {
+		t.Fatal("ReloadConfigLogged() succeeded with an invalid config")
+	}
+	if got := GetString("log_level"); got != "debug" {
+		t.Fatalf("log_level = %q after a failed reload, want the previous config kept", got)
+	}
+	if logs.FilterMessage("config reload failed, keeping previous config").Len() != 1 {
+		t.Fatalf("logs = %v, want the failed reload logged", logs.All())
+	}
+}
+
+// interruptedReader fails its first reads with EINTR, like a read on a network file
+// system interrupted by a signal.
+type interruptedReader struct {
+	r          io.Reader
+	interrupts int
+}
+
+func (i *interruptedReader) Read(p []byte) (int, error) {
+	if i.interrupts > 0 {
+		i.interrupts--
+		return 0, syscall.EINTR
+	}
+	return i.r.Read(p)
+}
+
+func TestParseKeployConfigEntriesRetriesEINTR(t *testing.T) {
+	entries, err := ParseKeployConfigEntries(&interruptedReader{r: strings.NewReader("log_level=debug\n"), interrupts: 2})
+	if err != nil || len(entries) != 1 || entries[0].Value != "debug" {
+		t.Fatalf("ParseKeployConfigEntries() = %+v, %v, want the config read after the interrupts", entries, err)
+	}
+
+	_, err = ParseKeployConfigEntries(&interruptedReader{r: strings.NewReader("log_level=debug\n"), interrupts: maxEINTRRetries + 1})
+	if !errors.Is(err, syscall.EINTR) {
+		t.Fatalf("ParseKeployConfigEntries() error = %v, want EINTR once the retries are exhausted", err)
+	}
+}
+
+func TestCanWriteConfig(t *testing.T) {
+	home := useKeployHome(t, "")
+	// A missing keploy directory is writable when it can be created.
+	t.Setenv("KEPLOY_HOME", filepath.Join(home, "missing", "keploy"))
+	if writable, err := CanWriteConfig(); !writable {
+		t.Fatalf("CanWriteConfig() = false, %v, want a creatable directory writable", err)
+	}
+
+	file := filepath.Join(home, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KEPLOY_HOME", file)
+	if writable, _ := CanWriteConfig(); writable {
+		t.Fatal("CanWriteConfig() = true for a keploy home which is a file")
+	}
+}
+
+func TestReadKeployConfigProfile(t *testing.T) {
+	useKeployHome(t, "log_level=info\nactive_profile=prod\nprod.log_level=warn\nstaging.log_level=debug\n")
+
+	config, err := ReadKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config["log_level"] != "warn" {
+		t.Fatalf("log_level = %q, want the value of the active profile", config["log_level"])
+	}
+
+	t.Setenv(profileEnvVar, "staging")
+	if config, err = ReadKeployConfig(zap.NewNop()); err != nil || config["log_level"] != "debug" {
+		t.Fatalf("log_level = %q, %v, want KEPLOY_PROFILE to win over active_profile", config["log_level"], err)
+	}
+
+	t.Setenv(profileEnvVar, "qa")
+	core, logs := observer.New(zapcore.WarnLevel)
+	if config, err = ReadKeployConfig(zap.New(core)); err != nil || config["log_level"] != "info" {
+		t.Fatalf("log_level = %q, %v, want the base settings for an unknown profile", config["log_level"], err)
+	}
+	if logs.FilterMessage("unknown keploy config profile, using the base settings").Len() != 1 {
+		t.Fatalf("logs = %v, want the unknown profile reported", logs.All())
+	}
+}
+
+func TestParseKeployConfigStrict(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantLine   int
+		wantReason string
+	}{
+		{"missing equals", "update_pref=yes\nbroken\n", 2, "expected key=value"},
+		{"invalid key", "# comment\nbad key=1\n", 2, `invalid key "bad key"`},
+		{"control character", "a=\x01\n", 1, "control character"},
+		{"invalid UTF-8", "a=\xff\n", 1, "invalid UTF-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeployConfigStrict(strings.NewReader(tt.content))
+			var parseErr *ConfigParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("err = %v, want a *ConfigParseError", err)
+			}
+			if parseErr.Line != tt.wantLine || parseErr.Reason != tt.wantReason {
+				t.Fatalf("error = line %d %q, want line %d %q", parseErr.Line, parseErr.Reason, tt.wantLine, tt.wantReason)
+			}
+		})
+	}
+
+	config, err := ParseKeployConfigStrict(strings.NewReader("# comment\n\nupdate_pref = no\n"))
+	if err != nil || config["update_pref"] != "no" {
+		t.Fatalf("config = %v, %v, want a valid config parsed", config, err)
+	}
+}
+
+func TestReadKeployConfigMalformedLine(t *testing.T) {
+	useKeployHome(t, "update_pref=no\nbroken\n")
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	config, err := ReadKeployConfig(zap.New(core))
+	if err != nil || config["update_pref"] != "no" {
+		t.Fatalf("config = %v, %v, want the malformed line skipped", config, err)
+	}
+	warnings := logs.FilterMessage("ignoring a malformed line of the keploy config").All()
+	if len(warnings) != 1 || warnings[0].ContextMap()["line"] != int64(2) {
+		t.Fatalf("logs = %v, want the malformed line 2 reported", logs.All())
+	}
+
+	t.Setenv(strictConfigEnvVar, "true")
+	var parseErr *ConfigParseError
+	if _, err := ReadKeployConfig(zap.NewNop()); !errors.As(err, &parseErr) || parseErr.Line != 2 {
+		t.Fatalf("err = %v, want a *ConfigParseError for line 2 in strict mode", err)
+	}
+}
+
+func TestReloadConfigSendsOnlyReloaded(t *testing.T) {
+	useKeployHome(t, "update_pref=yes\n")
+	var events []ConfigEventType
+	SetConfigEventHandler(func(event ConfigEvent) { events = append(events, event.Type) })
+	t.Cleanup(func() { SetConfigEventHandler(nil) })
+
+	if err := ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadConfigLogged(zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0] != ConfigReloaded || events[1] != ConfigReloaded {
+		t.Fatalf("events = %v, want a single reloaded event per reload", events)
+	}
+}
+
+func TestReadKeployConfigResolvesRelativePaths(t *testing.T) {
+	home := useKeployHome(t, "log_file=logs/keploy.log\nprod.log_file=prod.log\nupdate_url=relative/url\n")
+	config, err := ReadKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, "logs", "keploy.log"); config["log_file"] != want {
+		t.Fatalf("log_file = %q, want %q relative to the config file", config["log_file"], want)
+	}
+	if want := filepath.Join(home, "prod.log"); config["prod.log_file"] != want {
+		t.Fatalf("prod.log_file = %q, want the profile setting resolved to %q", config["prod.log_file"], want)
+	}
+	if config["update_url"] != "relative/url" {
+		t.Fatalf("update_url = %q, want a setting which is not a path untouched", config["update_url"])
+	}
+
+	abs := filepath.Join(t.TempDir(), "keploy.log")
+	useKeployHome(t, "log_file="+abs+"\n")
+	if config, err = ReadKeployConfig(zap.NewNop()); err != nil || config["log_file"] != abs {
+		t.Fatalf("log_file = %q, %v, want the absolute path %q kept", config["log_file"], err, abs)
+	}
+}
+
+func TestWriteConfigRefusedWithConfigURL(t *testing.T) {
+	home := useKeployHome(t, "")
+	t.Setenv(configURLEnvVar, "https://config.example.com/keploy.conf")
+	assertConfigWriteRefused(t, home)
+}
+
+// writeSecret writes a secret file in a temporary directory and returns its path.
+func writeSecret(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadConfigSecretFile(t *testing.T) {
+	secret := writeSecret(t, "s3cr3t\n")
+	useKeployHome(t, "api_key_file="+secret+"\n")
+
+	config, err := ReadKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config["api_key"] != "s3cr3t" {
+		t.Fatalf("api_key = %q, want the trimmed content of the secret file", config["api_key"])
+	}
+	if _, ok := config["api_key_file"]; ok {
+		t.Fatal("api_key_file is still set after reading the secret file")
+	}
+}
+
+func TestReadConfigSecretFileConflict(t *testing.T) {
+	secret := writeSecret(t, "s3cr3t")
+	useKeployHome(t, "api_key=inline\napi_key_file="+secret+"\n")
+
+	_, err := ReadKeployConfig(zap.NewNop())
+	if err == nil || !strings.Contains(err.Error(), "can't both be set") {
+		t.Fatalf("ReadKeployConfig() error = %v, want a conflict error", err)
+	}
+}
+
+func TestReadConfigProfileSecretFile(t *testing.T) {
+	secret := writeSecret(t, "prod-key\n")
+	useKeployHome(t, "api_key=dev\nprod.api_key_file="+secret+"\n")
+	t.Setenv(profileEnvVar, "prod")
+
+	config, err := ReadKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatalf("ReadKeployConfig() error = %v", err)
+	}
+	if config["api_key"] != "prod-key" {
+		t.Fatalf("api_key = %q, want the content of the prod secret file", config["api_key"])
+	}
+}
+
+func TestReadConfigProfileReplacesBaseSecretFile(t *testing.T) {
+	secret := writeSecret(t, "dev-key")
+	useKeployHome(t, "api_key_file="+secret+"\nprod.api_key=prod-key\n")
+	t.Setenv(profileEnvVar, "prod")
+
+	config, err := ReadKeployConfig(zap.NewNop())
+	if err != nil {
+		t.Fatalf("ReadKeployConfig() error = %v", err)
+	}
+	if config["api_key"] != "prod-key" {
+		t.Fatalf("api_key = %q, want the prod profile value", config["api_key"])
+	}
+}
@@ -0,0 +1,153 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"go.keploy.io/server/v2/utils"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// defaultLogMaxSizeMB is the size at which the log file is rotated.
+	defaultLogMaxSizeMB = 10
+	// maxLogMaxSizeMB is the highest accepted log_max_size_mb.
+	maxLogMaxSizeMB = 1024
+	// logMaxBackups is the number of rotated log files kept next to the log file.
+	logMaxBackups = 3
+)
+
+// fileCore is the core writing the logs to the file set by the log_file setting, nil when unset.
+var fileCore zapcore.Core
+
+// initFileCore sets up the log file core from the log_file, log_level and
+// log_max_size_mb keys of the keploy user settings.
+func initFileCore() error {
+	fileCore = nil
+
+	config, err := utils.GlobalConfig()
+	if err != nil {
+		return fmt.Errorf("failed to read the keploy config: %v", err)
+	}
+	path := config["log_file"]
+	if path == "" {
+		return nil
+	}
+
+	level := zapcore.DebugLevel
+	if value, ok := config["log_level"]; ok {
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid log_level %q: %v", value, err)
+		}
+	}
+
+	maxSizeMB, err := utils.GetIntInRange("log_max_size_mb", 1, maxLogMaxSizeMB, defaultLogMaxSizeMB)
+	if err != nil {
+		return err
+	}
+
+	writer, err := newRotatingWriter(path, int64(maxSizeMB)*1024*1024)
+	if err != nil {
+		return err
+	}
+
+	encoderCfg := zap.NewDevelopmentEncoderConfig()
+	encoderCfg.EncodeTime = customTimeEncoder
+	fileCore = zapcore.NewCore(zapcore.NewConsoleEncoder(encoderCfg), zapcore.AddSync(writer), level)
+	return nil
+}
+
+// build builds the logger from the config, teeing the output to the log file if one is
+// set and masking the secrets of the keploy config in every entry.
+func build(cfg zap.Config) (*zap.Logger, error) {
+	return cfg.Build(zap.WrapCore(wrapCore))
+}
+
+// wrapCore tees core to the log file core if one is set. Each of them is wrapped on its
+// own in the masking, so that every core keeps checking the level of the entries.
+func wrapCore(core zapcore.Core) zapcore.Core {
+	cores := []zapcore.Core{core}
+	if fileCore != nil {
+		cores = append(cores, fileCore)
+	}
+	if len(secrets) > 0 {
+		for i, c := range cores {
+			cores[i] = newMaskingCore(c, secrets)
+		}
+	}
+	return zapcore.NewTee(cores...)
+}
+
+// rotatingWriter is a file writer which rotates the file once it grows over maxSize:
+// the file is renamed to <path>.1 (shifting the older backups) and a new file is started.
+type rotatingWriter struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	size    int64
+	file    *os.File
+}
+
+func newRotatingWriter(path string, maxSize int64) (*rotatingWriter, error) {
+	w := &rotatingWriter{path: path, maxSize: maxSize}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open the log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to get the log file info: %v", err)
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotatingWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Sync()
+}
+
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	for i := logMaxBackups - 1; i > 0; i-- {
+		older := w.path + "." + strconv.Itoa(i)
+		if _, err := os.Stat(older); err == nil {
+			if err := os.Rename(older, w.path+"."+strconv.Itoa(i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return err
+	}
+	return w.open()
+}
@@ -0,0 +1,98 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.keploy.io/server/v2/utils"
+)
+
+func TestRotatingWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keploy.log")
+	w, err := newRotatingWriter(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.file.Close() })
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n", "fifth\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "fifth\n",
+		path + ".1": "fourth\n",
+		path + ".2": "third\n",
+		path + ".3": "second\n",
+	}
+	for file, content := range want {
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), got, content)
+		}
+	}
+	if _, err := os.Stat(path + ".4"); !os.IsNotExist(err) {
+		t.Fatalf("found more than %d backups", logMaxBackups)
+	}
+}
+
+func TestNewTeesToLogFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("KEPLOY_HOME", home)
+	path := filepath.Join(home, "keploy.log")
+	if err := os.WriteFile(filepath.Join(home, "config"), []byte("log_file="+path+"\nlog_level=info\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fileCore = nil })
+	// New creates keploy-logs.txt in the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	logger, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("below the file level")
+	logger.Info("written to the file")
+	_ = logger.Sync()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "written to the file") || strings.Contains(string(got), "below the file level") {
+		t.Fatalf("log file = %q, want only the logs at or above log_level", got)
+	}
+}
+
+func TestInitFileCoreInvalidSettings(t *testing.T) {
+	for _, config := range []string{"log_file=x\nlog_level=loud\n", "log_file=x\nlog_max_size_mb=0\n"} {
+		home := t.TempDir()
+		t.Setenv("KEPLOY_HOME", home)
+		if err := os.WriteFile(filepath.Join(home, "config"), []byte(config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := utils.ReloadConfig(); err != nil {
+			t.Fatal(err)
+		}
+		if err := initFileCore(); err == nil {
+			t.Errorf("initFileCore() accepted the config %q", config)
+		}
+	}
+}
@@ -32,13 +32,19 @@ func New() (*zap.Logger, error) {
 	LogCfg.EncoderConfig.EncodeTime = customTimeEncoder
 	LogCfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 
+	registerFdSink()
+	output, err := logOutput()
+	if err != nil {
+		log.Println(Emoji, "failed to set up the log output, using stdout", err)
+		output = "stdout"
+	}
 	LogCfg.OutputPaths = []string{
-		"stdout",
+		output,
 		"./keploy-logs.txt",
 	}
 
 	// Check if keploy-log.txt exists, if not create it.
-	_, err := os.Stat("keploy-logs.txt")
+	_, err = os.Stat("keploy-logs.txt")
 	if os.IsNotExist(err) {
 		_, err := os.Create("keploy-logs.txt")
 		if err != nil {
@@ -65,7 +71,13 @@ func New() (*zap.Logger, error) {
 	LogCfg.DisableStacktrace = true
 	LogCfg.EncoderConfig.EncodeCaller = nil
 
-	logger, err := LogCfg.Build()
+	initSecrets()
+	// Tee the logs to the log file set in the keploy config, if any.
+	if err := initFileCore(); err != nil {
+		log.Println(Emoji, "failed to set up the log file", err)
+	}
+
+	logger, err := build(LogCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build config for logger: %v", err)
 	}
@@ -79,7 +91,7 @@ func ChangeLogLevel(level zapcore.Level) (*zap.Logger, error) {
 		LogCfg.EncoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
 	}
 
-	logger, err := LogCfg.Build()
+	logger, err := build(LogCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build config for logger: %v", err)
 	}
@@ -96,7 +108,7 @@ func AddMode(mode string) (*zap.Logger, error) {
 		enc.AppendString(emoji + " " + mode + " " + t.Format(time.RFC3339) + " ")
 	}
 	// Rebuild the logger with the updated configuration
-	newLogger, err := cfg.Build()
+	newLogger, err := build(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to add mode to logger: %v", err)
 	}
@@ -105,7 +117,7 @@ func AddMode(mode string) (*zap.Logger, error) {
 
 func ChangeColorEncoding() (*zap.Logger, error) {
 	LogCfg.Encoding = "nonColorConsole"
-	logger, err := LogCfg.Build()
+	logger, err := build(LogCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build config for logger: %v", err)
 	}
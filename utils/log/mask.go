@@ -0,0 +1,99 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.keploy.io/server/v2/utils"
+	"go.uber.org/zap/zapcore"
+)
+
+// maskedValue replaces the secrets found in the logs.
+const maskedValue = "***"
+
+// secrets are the values of the sensitive keploy settings, masked in every log entry.
+var secrets []string
+
+// initSecrets loads the secret values to mask from the keploy user settings.
+func initSecrets() {
+	secrets = nil
+	config, err := utils.GlobalConfig()
+	if err != nil {
+		return
+	}
+	secrets = utils.SensitiveConfigValues(config)
+}
+
+// maskingCore wraps a core and replaces any known secret found in the message or
+// in the string, error, stringer, byte string and reflected fields of an entry before
+// it is written. A field holding a secret is written as a masked string.
+type maskingCore struct {
+	zapcore.Core
+	secrets []string
+}
+
+func newMaskingCore(core zapcore.Core, secrets []string) zapcore.Core {
+	return &maskingCore{Core: core, secrets: secrets}
+}
+
+func (c *maskingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &maskingCore{Core: c.Core.With(c.maskFields(fields)), secrets: c.secrets}
+}
+
+func (c *maskingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *maskingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = c.mask(ent.Message)
+	return c.Core.Write(ent, c.maskFields(fields))
+}
+
+func (c *maskingCore) maskFields(fields []zapcore.Field) []zapcore.Field {
+	masked := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.StringType:
+			f.String = c.mask(f.String)
+		case zapcore.ByteStringType:
+			if b, ok := f.Interface.([]byte); ok {
+				f = c.maskedString(f, string(b))
+			}
+		case zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok && err != nil {
+				f = c.maskedString(f, err.Error())
+			}
+		case zapcore.StringerType:
+			if s, ok := f.Interface.(fmt.Stringer); ok && s != nil {
+				f = c.maskedString(f, s.String())
+			}
+		case zapcore.ReflectType:
+			// The value is written as JSON, a secret can hide in any of its members.
+			if data, err := json.Marshal(f.Interface); err == nil {
+				f = c.maskedString(f, string(data))
+			}
+		}
+		masked[i] = f
+	}
+	return masked
+}
+
+// maskedString returns f as a string field holding the masked value when value, the
+// text f is written as, holds a secret, and f itself otherwise.
+func (c *maskingCore) maskedString(f zapcore.Field, value string) zapcore.Field {
+	if msg := c.mask(value); msg != value {
+		return zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: msg}
+	}
+	return f
+}
+
+func (c *maskingCore) mask(s string) string {
+	for _, secret := range c.secrets {
+		s = strings.ReplaceAll(s, secret, maskedValue)
+	}
+	return s
+}
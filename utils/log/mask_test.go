@@ -0,0 +1,83 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const testSecret = "sk-test-1234"
+
+// useSecrets sets the secrets masked by the loggers built during the test.
+func useSecrets(t *testing.T) {
+	t.Helper()
+	previous := secrets
+	secrets = []string{testSecret}
+	t.Cleanup(func() { secrets = previous })
+}
+
+// useFileCore sets the log file core of the loggers built during the test.
+func useFileCore(t *testing.T, core zapcore.Core) {
+	t.Helper()
+	previous := fileCore
+	fileCore = core
+	t.Cleanup(func() { fileCore = previous })
+}
+
+type secretStringer struct{}
+
+func (secretStringer) String() string { return "key=" + testSecret }
+
+func TestMaskingCoreMasksSecrets(t *testing.T) {
+	useSecrets(t)
+	useFileCore(t, nil)
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(wrapCore(core))
+
+	logger.Info("calling https://api.keploy.io?key="+testSecret,
+		zap.String("url", "https://api.keploy.io?key="+testSecret),
+		zap.Error(errors.New("invalid key "+testSecret)),
+		zap.Stringer("stringer", secretStringer{}),
+		zap.ByteString("body", []byte(`{"api_key":"`+testSecret+`"}`)),
+		zap.Reflect("query", url.Values{"key": {testSecret}}),
+	)
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(entries))
+	}
+	if strings.Contains(entries[0].Message, testSecret) {
+		t.Fatalf("message %q holds the secret", entries[0].Message)
+	}
+	for key, field := range entries[0].ContextMap() {
+		value := fmt.Sprint(field)
+		if strings.Contains(value, testSecret) {
+			t.Errorf("field %s = %q holds the secret", key, value)
+		} else if !strings.Contains(value, maskedValue) {
+			t.Errorf("field %s = %q, want the secret masked", key, value)
+		}
+	}
+}
+
+func TestMaskingCoreKeepsLevelOfEachCore(t *testing.T) {
+	useSecrets(t)
+	file, fileLogs := observer.New(zapcore.DebugLevel)
+	useFileCore(t, file)
+	console, consoleLogs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(wrapCore(console))
+
+	logger.Debug("debug details")
+
+	if n := consoleLogs.Len(); n != 0 {
+		t.Fatalf("the info console logged %d debug entries, want none", n)
+	}
+	if n := fileLogs.Len(); n != 1 {
+		t.Fatalf("the debug log file logged %d entries, want 1", n)
+	}
+}
@@ -0,0 +1,55 @@
+package log
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.keploy.io/server/v2/utils"
+	"go.uber.org/zap"
+)
+
+// fdSinkScheme is the scheme of the "fd:N" log outputs.
+const fdSinkScheme = "fd"
+
+// registerFdSink lets zap write to an inherited file descriptor, e.g. a pipe set up by
+// a sidecar, using "fd:N" output paths.
+func registerFdSink() {
+	_ = zap.RegisterSink(fdSinkScheme, func(u *url.URL) (zap.Sink, error) {
+		fd, err := strconv.Atoi(u.Opaque)
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid file descriptor %q", u.Opaque)
+		}
+		return os.NewFile(uintptr(fd), "fd:"+u.Opaque), nil
+	})
+}
+
+// logOutput returns the zap output path of the console logs from the log_output key
+// of the keploy config: "stdout" (the default), "stderr", "fd:N" or a file path.
+func logOutput() (string, error) {
+	return resolveLogOutput(utils.GetString("log_output"))
+}
+
+func resolveLogOutput(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "" || value == "stdout":
+		return "stdout", nil
+	case value == "stderr":
+		return "stderr", nil
+	case strings.HasPrefix(value, fdSinkScheme+":"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(value, fdSinkScheme+":"))
+		if err != nil || fd < 0 {
+			return "", fmt.Errorf("invalid log_output %q: expected fd:N with N a file descriptor number", value)
+		}
+		return fdSinkScheme + ":" + strconv.Itoa(fd), nil
+	default:
+		path, err := utils.ExpandPath(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid log_output %q: %v", value, err)
+		}
+		return path, nil
+	}
+}
@@ -0,0 +1,69 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.keploy.io/server/v2/utils"
+)
+
+func TestResolveLogOutput(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "stdout", false},
+		{" stdout ", "stdout", false},
+		{"stderr", "stderr", false},
+		{"fd:3", "fd:3", false},
+		{"fd:03", "fd:3", false},
+		{"fd:-1", "", true},
+		{"fd:pipe", "", true},
+		{"/var/log/keploy.log", "/var/log/keploy.log", false},
+	}
+	for _, tt := range tests {
+		got, err := resolveLogOutput(tt.value)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("resolveLogOutput(%q) = %q, %v, want %q, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNewLogOutputFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("KEPLOY_HOME", home)
+	path := filepath.Join(home, "console.log")
+	if err := os.WriteFile(filepath.Join(home, "config"), []byte("log_output="+path+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	// New creates keploy-logs.txt in the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	logger, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("routed to log_output")
+	_ = logger.Sync()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "routed to log_output") {
+		t.Fatalf("log_output file = %q, want the console logs", got)
+	}
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// errorSampleWindow is the period during which identical errors are logged only once.
+var errorSampleWindow = 10 * time.Second
+
+var (
+	sampledErrorsMu sync.Mutex
+	// sampledErrors counts the suppressed occurrences of each error logged in the current window.
+	sampledErrors = map[string]*int{}
+)
+
+// LogErrorSampled behaves like LogError but rate limits identical errors: the first
+// occurrence of an (error, msg) pair is logged, the following ones within the sample
+// window are counted and reported in a single "N more occurrences" summary at the end
+// of the window. It keeps the logs readable when a tight loop keeps failing.
+func LogErrorSampled(logger *zap.Logger, err error, msg string, fields ...zap.Field) {
+	if logger == nil {
+		fmt.Println("Failed to log error. Logger is nil.")
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	key := msg
+	if err != nil {
+		key += "\x00" + err.Error()
+	}
+
+	sampledErrorsMu.Lock()
+	if suppressed, ok := sampledErrors[key]; ok {
+		*suppressed++
+		sampledErrorsMu.Unlock()
+		return
+	}
+	suppressed := new(int)
+	sampledErrors[key] = suppressed
+	sampledErrorsMu.Unlock()
+
+	LogError(logger, err, msg, fields...)
+
+	time.AfterFunc(errorSampleWindow, func() {
+		sampledErrorsMu.Lock()
+		n := *suppressed
+		delete(sampledErrors, key)
+		sampledErrorsMu.Unlock()
+
+		if n > 0 {
+			LogError(logger, err, fmt.Sprintf("%s (%d more occurrences in the last %s)", msg, n, errorSampleWindow), fields...)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogErrorSampled(t *testing.T) {
+	previous := errorSampleWindow
+	errorSampleWindow = 50 * time.Millisecond
+	t.Cleanup(func() { errorSampleWindow = previous })
+	core, logs := observer.New(zapcore.ErrorLevel)
+	logger := zap.New(core)
+	err := errors.New("connection refused")
+
+	for i := 0; i < 5; i++ {
+		LogErrorSampled(logger, err, "failed to reach the proxy")
+	}
+	LogErrorSampled(logger, errors.New("timeout"), "failed to reach the proxy")
+	if got := logs.Len(); got != 2 {
+		t.Fatalf("logged %d errors, want the first occurrence of each error", got)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for logs.FilterMessage("failed to reach the proxy (4 more occurrences in the last 50ms)").Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("logs = %v, want a summary of the suppressed occurrences", logs.All())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The window is over, the error is logged again.
+	LogErrorSampled(logger, err, "failed to reach the proxy")
+	if got := logs.FilterMessage("failed to reach the proxy").Len(); got != 3 {
+		t.Fatalf("logged %d occurrences after the window, want the error logged again", got)
+	}
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultNetworkRetries is the number of retries of a failed network call.
+	defaultNetworkRetries = 2
+	// maxNetworkRetries is the highest accepted network_retries.
+	maxNetworkRetries = 10
+	// defaultNetworkRetryBackoff is the wait before the first retry, doubled on every retry.
+	defaultNetworkRetryBackoff = 500 * time.Millisecond
+)
+
+// networkRetries returns the network_retries setting. An invalid value gives the
+// default with a warning.
+func networkRetries(logger *zap.Logger) int {
+	retries, err := GetIntInRange("network_retries", 0, maxNetworkRetries, defaultNetworkRetries)
+	if err != nil {
+		if logger != nil {
+			logger.Warn("invalid network_retries in the keploy config, using the default", zap.Error(err), zap.Int("default", defaultNetworkRetries))
+		}
+		return defaultNetworkRetries
+	}
+	return retries
+}
+
+// networkRetryBackoff returns the network_retry_backoff setting. An invalid value
+// gives the default with a warning.
+func networkRetryBackoff(logger *zap.Logger) time.Duration {
+	value := GetString("network_retry_backoff")
+	if value == "" {
+		return defaultNetworkRetryBackoff
+	}
+	backoff, err := time.ParseDuration(value)
+	if err != nil || backoff < 0 {
+		if logger != nil {
+			logger.Warn("invalid network_retry_backoff in the keploy config, using the default", zap.String("value", value), zap.Duration("default", defaultNetworkRetryBackoff))
+		}
+		return defaultNetworkRetryBackoff
+	}
+	return backoff
+}
+
+// withNetworkRetries calls fn until it succeeds, retrying it network_retries times
+// with an exponential backoff starting at network_retry_backoff. It gives up as soon
+// as ctx is done and returns the last error of fn.
+func withNetworkRetries(ctx context.Context, logger *zap.Logger, fn func() error) error {
+	retries := networkRetries(logger)
+	backoff := networkRetryBackoff(logger)
+
+	err := fn()
+	for retry := 1; err != nil && retry <= retries; retry++ {
+		if ctx.Err() != nil {
+			return err
+		}
+		if logger != nil {
+			logger.Debug("network call failed, retrying", zap.Error(err), zap.Int("retry", retry), zap.Duration("backoff", backoff))
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-clock.After(backoff):
+		}
+		backoff *= 2
+		err = fn()
+	}
+	return err
+}
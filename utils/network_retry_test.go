@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// recordingClock is a Clock whose timers fire at once, recording their durations.
+type recordingClock struct {
+	waits *[]time.Duration
+}
+
+func (c recordingClock) Now() time.Time { return time.Now() }
+
+func (c recordingClock) After(d time.Duration) <-chan time.Time {
+	*c.waits = append(*c.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- time.Now()
+	return ch
+}
+
+func TestWithNetworkRetries(t *testing.T) {
+	useKeployHome(t, "network_retries=3\nnetwork_retry_backoff=100ms\n")
+	var waits []time.Duration
+	SetClock(recordingClock{waits: &waits})
+	t.Cleanup(func() { SetClock(nil) })
+
+	calls := 0
+	errUnavailable := errors.New("unavailable")
+	err := withNetworkRetries(context.Background(), zap.NewNop(), func() error {
+		calls++
+		return errUnavailable
+	})
+	if !errors.Is(err, errUnavailable) || calls != 4 {
+		t.Fatalf("withNetworkRetries() = %v after %d calls, want the last error after 4 calls", err, calls)
+	}
+	if want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}; !reflect.DeepEqual(waits, want) {
+		t.Fatalf("backoffs = %v, want %v", waits, want)
+	}
+
+	calls = 0
+	if err := withNetworkRetries(context.Background(), zap.NewNop(), func() error {
+		calls++
+		if calls < 2 {
+			return errUnavailable
+		}
+		return nil
+	}); err != nil || calls != 2 {
+		t.Fatalf("withNetworkRetries() = %v after %d calls, want success on the retry", err, calls)
+	}
+}
+
+func TestWithNetworkRetriesCanceled(t *testing.T) {
+	useKeployHome(t, "network_retries=5\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	_ = withNetworkRetries(ctx, zap.NewNop(), func() error {
+		calls++
+		return errors.New("unavailable")
+	})
+	if calls != 1 {
+		t.Fatalf("fn was called %d times with a canceled context, want once", calls)
+	}
+}
+
+func TestNetworkRetrySettingsInvalid(t *testing.T) {
+	useKeployHome(t, "network_retries=99\nnetwork_retry_backoff=-1s\n")
+	if got := networkRetries(zap.NewNop()); got != defaultNetworkRetries {
+		t.Fatalf("networkRetries() = %d, want the default for an out of range value", got)
+	}
+	if got := networkRetryBackoff(zap.NewNop()); got != defaultNetworkRetryBackoff {
+		t.Fatalf("networkRetryBackoff() = %v, want the default for a negative value", got)
+	}
+}
@@ -0,0 +1,50 @@
+//go:build !windows
+
+package utils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fileOwner returns the owner of the file at path, ok is false when it is unknown.
+func fileOwner(path string) (uid, gid int, ok bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
+
+// sudoOwner returns the user who ran keploy with sudo, from the SUDO_UID and SUDO_GID
+// variables set by sudo. ok is false when keploy doesn't run as root under sudo.
+func sudoOwner() (uid, gid int, ok bool) {
+	if os.Geteuid() != 0 {
+		return 0, 0, false
+	}
+	uid, uidErr := strconv.Atoi(os.Getenv("SUDO_UID"))
+	gid, gidErr := strconv.Atoi(os.Getenv("SUDO_GID"))
+	if uidErr != nil || gidErr != nil {
+		return 0, 0, false
+	}
+	return uid, gid, true
+}
+
+// restoreOwner gives the file at path to the given owner. It is skipped, without an
+// error, when the process is not allowed to change the owner.
+func restoreOwner(path string, uid, gid int) error {
+	if curUID, curGID, ok := fileOwner(path); ok && curUID == uid && curGID == gid {
+		return nil
+	}
+	if err := os.Chown(path, uid, gid); err != nil && !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	return nil
+}
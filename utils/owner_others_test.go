@@ -0,0 +1,45 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestWriteKeployConfigPreservesOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner of a file requires root")
+	}
+	useKeployHome(t, "update_pref=yes\n")
+	path := KeployConfigPath()
+	const uid, gid = 4242, 4343
+	if err := os.Chown(path, uid, gid); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteKeployConfig(zap.NewNop(), map[string]string{"update_pref": "no"}); err != nil {
+		t.Fatal(err)
+	}
+	if gotUID, gotGID, ok := fileOwner(path); !ok || gotUID != uid || gotGID != gid {
+		t.Fatalf("owner = %d:%d, want the previous owner %d:%d kept", gotUID, gotGID, uid, gid)
+	}
+}
+
+func TestRestoreOwnerUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	uid, gid, ok := fileOwner(path)
+	if !ok {
+		t.Fatal("fileOwner() failed on an existing file")
+	}
+	// Giving a file to its owner needs no privilege.
+	if err := restoreOwner(path, uid, gid); err != nil {
+		t.Fatalf("restoreOwner() error = %v", err)
+	}
+}
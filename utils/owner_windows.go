@@ -0,0 +1,17 @@
+//go:build windows
+
+package utils
+
+// fileOwner is not supported on windows, where the files don't have a uid/gid owner.
+func fileOwner(_ string) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+// sudoOwner is not supported on windows, which has no sudo.
+func sudoOwner() (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+func restoreOwner(_ string, _, _ int) error {
+	return nil
+}
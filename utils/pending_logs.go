@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxPendingLogs bounds the entries kept until a logger is registered.
+const maxPendingLogs = 100
+
+var (
+	pendingLogsMu sync.Mutex
+	// pendingLogs holds the entries logged before SetLogger, see pendingLogger.
+	pendingLogs []pendingLog
+)
+
+type pendingLog struct {
+	entry  zapcore.Entry
+	fields []zapcore.Field
+}
+
+// pendingLogger returns the registered logger or, before SetLogger, a logger keeping
+// its entries until one is registered. The config is loaded before the logger exists,
+// e.g. to set up the signals and the log file, and its warnings must not be lost.
+func pendingLogger() *zap.Logger {
+	if logger := currentLogger(); logger != nil {
+		return logger
+	}
+	return zap.New(pendingCore{})
+}
+
+// flushPendingLogs writes the entries kept by pendingLogger to logger.
+func flushPendingLogs(logger *zap.Logger) {
+	pendingLogsMu.Lock()
+	pending := pendingLogs
+	pendingLogs = nil
+	pendingLogsMu.Unlock()
+
+	for _, p := range pending {
+		if ce := logger.Check(p.entry.Level, p.entry.Message); ce != nil {
+			ce.Write(p.fields...)
+		}
+	}
+}
+
+// pendingCore keeps the info and higher entries in pendingLogs.
+type pendingCore struct {
+	fields []zapcore.Field
+}
+
+func (c pendingCore) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.InfoLevel
+}
+
+func (c pendingCore) With(fields []zapcore.Field) zapcore.Core {
+	return pendingCore{fields: append(append([]zapcore.Field(nil), c.fields...), fields...)}
+}
+
+func (c pendingCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c pendingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	pendingLogsMu.Lock()
+	defer pendingLogsMu.Unlock()
+	if len(pendingLogs) < maxPendingLogs {
+		pendingLogs = append(pendingLogs, pendingLog{entry: entry, fields: append(append([]zapcore.Field(nil), c.fields...), fields...)})
+	}
+	return nil
+}
+
+func (c pendingCore) Sync() error {
+	return nil
+}
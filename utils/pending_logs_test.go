@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestConfigWarningsLoggedBeforeSetLogger(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the config permissions aren't checked on windows")
+	}
+	ResetForTesting()
+	t.Cleanup(ResetForTesting)
+	useKeployHome(t, "github_token=secret\n")
+	if err := os.Chmod(KeployConfigPath(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The config is loaded before the logger exists, e.g. for shutdown_signals.
+	if err := ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	SetLogger(zap.New(core))
+
+	warnings := logs.FilterMessageSnippet("readable by other users")
+	if warnings.Len() != 1 {
+		t.Fatalf("logs = %v, want the permissions warning logged before SetLogger", logs.All())
+	}
+	if path := warnings.All()[0].ContextMap()["path"]; path != KeployConfigPath() {
+		t.Fatalf("path = %v, want %s", path, KeployConfigPath())
+	}
+}
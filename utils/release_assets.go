@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// ReleaseAssetName returns the name of the release archive for the given platform.
+func ReleaseAssetName(goos, goarch string) (string, error) {
+	if goarch != "amd64" && goarch != "arm64" {
+		return "", fmt.Errorf("unsupported architecture %s", goarch)
+	}
+	switch goos {
+	case "linux":
+		return "keploy_linux_" + goarch + ".tar.gz", nil
+	case "darwin":
+		// macOS releases ship a single universal binary, holding both the amd64 and
+		// the arm64 slices, so the native slice runs even when keploy itself runs as
+		// amd64 under Rosetta: the architecture doesn't pick the asset.
+		return "keploy_darwin_all.tar.gz", nil
+	default:
+		return "", fmt.Errorf("self update is not supported on %s", goos)
+	}
+}
+
+// HasAsset tells whether a file with the given name is attached to the release.
+func (r GitHubRelease) HasAsset(name string) bool {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyUpdateAvailableForPlatform tells whether the latest release has the archive of
+// the running platform attached. Assets are uploaded after the release is published,
+// so for a while a release may exist without the archive the update would download.
+func VerifyUpdateAvailableForPlatform(ctx context.Context) (bool, error) {
+	name, err := ReleaseAssetName(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return false, err
+	}
+	release, err := GetLatestGitHubRelease(ctx, currentLogger())
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch latest GitHub release version: %w", err)
+	}
+	return release.HasAsset(name), nil
+}
+
+// platformAsset returns the archive of the running platform attached to the release.
+func platformAsset(release GitHubRelease) (ReleaseAsset, bool) {
+	name, err := ReleaseAssetName(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return ReleaseAsset{}, false
+	}
+	for _, asset := range release.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return ReleaseAsset{}, false
+}
+
+// platformAssetMissing tells whether the release lists its assets but not the archive
+// of the running platform. A release without an asset list, e.g. from a custom
+// update_url, or a platform without self update isn't considered missing its asset.
+func platformAssetMissing(release GitHubRelease) bool {
+	if len(release.Assets) == 0 {
+		return false
+	}
+	if _, err := ReleaseAssetName(runtime.GOOS, runtime.GOARCH); err != nil {
+		return false
+	}
+	_, ok := platformAsset(release)
+	return !ok
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestReleaseAssetName(t *testing.T) {
+	tests := []struct {
+		goos, goarch string
+		want         string
+	}{
+		{goos: "linux", goarch: "amd64", want: "keploy_linux_amd64.tar.gz"},
+		{goos: "linux", goarch: "arm64", want: "keploy_linux_arm64.tar.gz"},
+		// macOS only has the universal binary, whichever slice runs, e.g. under Rosetta.
+		{goos: "darwin", goarch: "amd64", want: "keploy_darwin_all.tar.gz"},
+		{goos: "darwin", goarch: "arm64", want: "keploy_darwin_all.tar.gz"},
+	}
+	for _, tt := range tests {
+		got, err := ReleaseAssetName(tt.goos, tt.goarch)
+		if err != nil || got != tt.want {
+			t.Errorf("ReleaseAssetName(%s, %s) = %q, %v, want %q", tt.goos, tt.goarch, got, err, tt.want)
+		}
+	}
+}
+
+func TestReleaseAssetNameUnsupported(t *testing.T) {
+	for _, platform := range [][2]string{{"linux", "386"}, {"windows", "amd64"}} {
+		if _, err := ReleaseAssetName(platform[0], platform[1]); err == nil {
+			t.Errorf("ReleaseAssetName(%s, %s) succeeded, want an error", platform[0], platform[1])
+		}
+	}
+}
+
+func TestPlatformAssetMissing(t *testing.T) {
+	name, err := ReleaseAssetName(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		t.Skipf("no release asset for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	tests := []struct {
+		name    string
+		release GitHubRelease
+		want    bool
+	}{
+		{"asset attached", GitHubRelease{Assets: []ReleaseAsset{{Name: "checksums.txt"}, {Name: name}}}, false},
+		{"asset not uploaded yet", GitHubRelease{Assets: []ReleaseAsset{{Name: "checksums.txt"}}}, true},
+		// A custom update_url may not list the assets at all.
+		{"no asset list", GitHubRelease{}, false},
+	}
+	for _, tt := range tests {
+		if got := platformAssetMissing(tt.release); got != tt.want {
+			t.Errorf("%s: platformAssetMissing() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlatformAsset(t *testing.T) {
+	name, err := ReleaseAssetName(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		t.Skipf("no release asset for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	release := GitHubRelease{Assets: []ReleaseAsset{{Name: "checksums.txt", Size: 100}, {Name: name, Size: 4096}}}
+	if asset, ok := platformAsset(release); !ok || asset.Size != 4096 {
+		t.Fatalf("platformAsset() = %+v, %v, want the archive of the platform", asset, ok)
+	}
+	if _, ok := platformAsset(GitHubRelease{}); ok {
+		t.Fatal("platformAsset() found an asset in a release without any")
+	}
+}
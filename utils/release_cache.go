@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// releaseCacheFile is the name of the state file caching the latest release.
+const releaseCacheFile = "release_cache.json"
+
+// releaseCache is the latest release as last fetched from the update endpoint.
+type releaseCache struct {
+	TagName        string    `json:"tag_name"`
+	Body           string    `json:"body"`
+	MinimumVersion string    `json:"minimum_version,omitempty"`
+	FetchedAt      time.Time `json:"fetched_at"`
+}
+
+func readReleaseCache() (releaseCache, error) {
+	var cache releaseCache
+	data, err := os.ReadFile(StatePath(releaseCacheFile))
+	if err != nil {
+		return cache, err
+	}
+	err = json.Unmarshal(data, &cache)
+	return cache, err
+}
+
+func writeReleaseCache(release GitHubRelease) error {
+	data, err := json.Marshal(releaseCache{
+		TagName:        release.TagName,
+		Body:           release.Body,
+		MinimumVersion: release.MinimumVersion,
+		FetchedAt:      clock.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	path := StatePath(releaseCacheFile)
+	if err := EnsureStateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// releaseCacheTTL returns how long the cached release is considered fresh, which is
+// the update check interval.
+func releaseCacheTTL() time.Duration {
+	settings, err := LoadSettings()
+	if err != nil {
+		return DefaultSettings().UpdateCheckInterval
+	}
+	return settings.UpdateCheckInterval
+}
+
+// nextCheck returns when the cached release is no longer fresh.
+func (c releaseCache) nextCheck(interval time.Duration) time.Time {
+	return c.FetchedAt.Add(interval)
+}
+
+// freshReleaseCache returns the cached release when it was fetched less than the
+// update check interval ago.
+func freshReleaseCache() (releaseCache, bool) {
+	cache, err := readReleaseCache()
+	return cache, err == nil && clock.Now().Before(cache.nextCheck(releaseCacheTTL()))
+}
+
+// ReleaseNotes returns the release notes of the latest keploy release. They are read
+// from the release cache and fetched again only when the cache is older than the
+// update check interval, so "what's new" works offline after a first fetch. When the
+// fetch fails, stale notes are returned rather than nothing.
+func ReleaseNotes() (string, error) {
+	cache, cacheErr := readReleaseCache()
+	if cacheErr == nil && clock.Now().Before(cache.nextCheck(releaseCacheTTL())) {
+		return cache.Body, nil
+	}
+
+	logger := currentLogger()
+	release, err := getLatestRelease(context.Background(), logger)
+	if err != nil {
+		if cacheErr == nil {
+			if logger != nil {
+				logger.Debug("failed to refresh the release notes, using the cached ones", zap.Error(err))
+			}
+			return cache.Body, nil
+		}
+		return "", err
+	}
+	return release.Body, nil
+}
+
+// NextUpdateCheck returns when the next automatic update check is due: the time of the
+// last check, as recorded in the release cache, plus the update check interval. Until
+// then checkForUpdates doesn't fetch the release. A check which never ran is due now.
+// The zero time is returned when update checks are disabled.
+func NextUpdateCheck() (time.Time, error) {
+	logger := currentLogger()
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if enabled, known := storedUpdatePreference(logger); known && !enabled {
+		return time.Time{}, nil
+	}
+	settings, err := LoadSettings()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	cache, err := readReleaseCache()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return clock.Now(), nil
+		}
+		return time.Time{}, err
+	}
+	return cache.nextCheck(settings.UpdateCheckInterval), nil
+}
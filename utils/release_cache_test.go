@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// failingTransport fails every request, as when keploy runs offline.
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("network is unreachable")
+}
+
+func TestReleaseNotesOffline(t *testing.T) {
+	useKeployHome(t, "")
+	if err := writeReleaseCache(GitHubRelease{TagName: "v1.2.0", Body: "faster proxy"}); err != nil {
+		t.Fatal(err)
+	}
+	useTransport(t, failingTransport{})
+
+	notes, err := ReleaseNotes()
+	if err != nil || notes != "faster proxy" {
+		t.Fatalf("ReleaseNotes() = %q, %v, want the cached notes", notes, err)
+	}
+
+	// A stale cache is still better than nothing when the release can't be fetched.
+	useKeployHome(t, "update_check_interval=1ns\n")
+	if err := writeReleaseCache(GitHubRelease{TagName: "v1.2.0", Body: "stale notes"}); err != nil {
+		t.Fatal(err)
+	}
+	notes, err = ReleaseNotes()
+	if err != nil || notes != "stale notes" {
+		t.Fatalf("ReleaseNotes() = %q, %v, want the stale cached notes", notes, err)
+	}
+}
+
+func TestReleaseNotesWithoutCache(t *testing.T) {
+	useKeployHome(t, "")
+	useTransport(t, failingTransport{})
+	if _, err := ReleaseNotes(); err == nil {
+		t.Fatal("ReleaseNotes() succeeded without a cache nor a network")
+	}
+}
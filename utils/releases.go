@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxReleasesPerPage is the page size limit of the GitHub releases API.
+const maxReleasesPerPage = 100
+
+// Release is a published keploy release.
+type Release struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	Prerelease  bool      `json:"prerelease"`
+	Draft       bool      `json:"draft"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// releasesURL returns the endpoint listing the releases, derived from UpdateURL.
+func releasesURL() string {
+	return strings.TrimSuffix(strings.TrimSuffix(UpdateURL(), "/"), "/latest")
+}
+
+// ListReleases returns the limit most recent keploy releases, newest version first.
+// Pre-releases are included and flagged, drafts are left out. It backs the listing
+// of "keploy update --list" to find a version to pin or downgrade to.
+func ListReleases(ctx context.Context, limit int) ([]Release, error) {
+	if limit <= 0 {
+		return nil, errors.New("the number of releases to list must be positive")
+	}
+	perPage := limit
+	if perPage > maxReleasesPerPage {
+		perPage = maxReleasesPerPage
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releasesURL()+"?per_page="+strconv.Itoa(perPage), nil)
+	if err != nil {
+		return nil, err
+	}
+	setUpdateRequestHeaders(req)
+
+	client := HTTPClient(10 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			LogError(currentLogger(), err, "failed to close response body")
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d while listing the releases", resp.StatusCode)
+	}
+
+	var all []Release
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+		return nil, fmt.Errorf("failed to decode the releases: %w", err)
+	}
+
+	releases := make([]Release, 0, len(all))
+	for _, release := range all {
+		if !release.Draft {
+			releases = append(releases, release)
+		}
+	}
+	sortReleases(releases)
+	if len(releases) > limit {
+		releases = releases[:limit]
+	}
+	return releases, nil
+}
+
+// sortReleases sorts the releases by version, newest first. Tags which are not valid
+// versions are sorted by publication date after the valid ones.
+func sortReleases(releases []Release) {
+	sort.SliceStable(releases, func(i, j int) bool {
+		vi, erri := ParseVersion(releases[i].TagName)
+		vj, errj := ParseVersion(releases[j].TagName)
+		switch {
+		case erri == nil && errj == nil:
+			return vi.Compare(vj) > 0
+		case erri == nil:
+			return true
+		case errj == nil:
+			return false
+		default:
+			return releases[i].PublishedAt.After(releases[j].PublishedAt)
+		}
+	})
+}
+
+// FindRelease returns the release tagged with version, a "v" prefix is optional.
+func FindRelease(ctx context.Context, logger *zap.Logger, version string) (Release, error) {
+	want, err := ParseVersion(version)
+	if err != nil {
+		return Release{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releasesURL()+"/tags/"+want.String(), nil)
+	if err != nil {
+		return Release{}, err
+	}
+	setUpdateRequestHeaders(req)
+
+	client := HTTPClient(10 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return Release{}, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			LogError(logger, err, "failed to close response body")
+		}
+	}()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return Release{}, fmt.Errorf("keploy %s does not exist, run `keploy update --list` to see the available versions", want.String())
+	default:
+		return Release{}, fmt.Errorf("unexpected status code %d while fetching release %s", resp.StatusCode, want.String())
+	}
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return Release{}, fmt.Errorf("failed to decode release %s: %w", want.String(), err)
+	}
+	return release, nil
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestListReleases(t *testing.T) {
+	var perPage string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		perPage = r.URL.Query().Get("per_page")
+		_ = json.NewEncoder(w).Encode([]Release{
+			{TagName: "v1.1.0"},
+			{TagName: "v1.3.0-beta.1", Prerelease: true},
+			{TagName: "v1.4.0", Draft: true},
+			{TagName: "nightly"},
+			{TagName: "v1.2.0"},
+		})
+	}))
+	t.Cleanup(server.Close)
+	useKeployHome(t, "update_url="+server.URL+"/latest\n")
+
+	releases, err := ListReleases(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perPage != "3" {
+		t.Errorf("per_page = %q, want the limit", perPage)
+	}
+	var tags []string
+	for _, release := range releases {
+		tags = append(tags, release.TagName)
+	}
+	want := []string{"v1.3.0-beta.1", "v1.2.0", "v1.1.0"}
+	if len(tags) != len(want) {
+		t.Fatalf("ListReleases() = %v, want %v", tags, want)
+	}
+	for i := range want {
+		if tags[i] != want[i] {
+			t.Fatalf("ListReleases() = %v, want %v", tags, want)
+		}
+	}
+
+	if _, err := ListReleases(context.Background(), 0); err == nil {
+		t.Fatal("ListReleases() accepted a limit of 0")
+	}
+}
+
+func TestFindRelease(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tags/v1.2.0" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Release{TagName: "v1.2.0"})
+	}))
+	t.Cleanup(server.Close)
+	useKeployHome(t, "update_url="+server.URL+"/latest\n")
+
+	release, err := FindRelease(context.Background(), zap.NewNop(), "1.2.0")
+	if err != nil || release.TagName != "v1.2.0" {
+		t.Fatalf("FindRelease() = %+v, %v, want v1.2.0", release, err)
+	}
+	if _, err := FindRelease(context.Background(), zap.NewNop(), "v9.9.9"); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("FindRelease() error = %v, want the version reported as missing", err)
+	}
+	if _, err := FindRelease(context.Background(), zap.NewNop(), "latest"); err == nil {
+		t.Fatal("FindRelease() accepted an invalid version")
+	}
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// configURLEnvVar makes keploy read its config from an https endpoint, see LoadConfigFromURL.
+	configURLEnvVar = "KEPLOY_CONFIG_URL"
+	// remoteConfigTimeout bounds the fetch of a remote config.
+	remoteConfigTimeout = 10 * time.Second
+	// maxRemoteConfigSize is the largest remote config accepted.
+	maxRemoteConfigSize = 1 << 20
+)
+
+// redactURL returns the URL with its password, if any, masked.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+// remoteConfigCachePath returns the file caching the config fetched from rawURL.
+func remoteConfigCachePath(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return StatePath(fmt.Sprintf("remote_config_%x.cache", sum[:8]))
+}
+
+// LoadConfigFromURL fetches and parses the keploy config served at rawURL, e.g. by a
+// config service. Only https URLs are accepted. The fetched config is cached locally
+// and the cache is used when the endpoint can't be reached, so that keploy keeps
+// working offline with the last config it got.
+func LoadConfigFromURL(ctx context.Context, rawURL string) (map[string]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config URL %q: %w", rawURL, err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid config URL %q: only https is allowed", rawURL)
+	}
+
+	data, err := fetchRemoteConfig(ctx, rawURL)
+	cachePath := remoteConfigCachePath(rawURL)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		cached, cacheErr := os.ReadFile(cachePath)
+		if cacheErr != nil {
+			return nil, fmt.Errorf("failed to fetch the keploy config from %s: %w", u.Redacted(), err)
+		}
+		if logger := currentLogger(); logger != nil {
+			logger.Warn("failed to fetch the keploy config, using the cached one", zap.String("url", u.Redacted()), zap.Error(err))
+		}
+		return ParseKeployConfig(bytes.NewReader(cached))
+	}
+
+	config, err := ParseKeployConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the keploy config from %s: %w", u.Redacted(), err)
+	}
+	if err := writeRemoteConfigCache(cachePath, data); err != nil {
+		if logger := currentLogger(); logger != nil {
+			logger.Debug("failed to cache the remote keploy config", zap.Error(err))
+		}
+	}
+	return config, nil
+}
+
+func fetchRemoteConfig(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "keploy/"+Version)
+
+	resp, err := HTTPClient(remoteConfigTimeout).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			LogError(currentLogger(), err, "failed to close response body")
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRemoteConfigSize {
+		return nil, fmt.Errorf("the config is larger than %d bytes", maxRemoteConfigSize)
+	}
+	return data, nil
+}
+
+func writeRemoteConfigCache(path string, data []byte) error {
+	if err := EnsureStateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	// The remote config may hold credentials, keep the cache private.
+	return os.WriteFile(path, data, 0600)
+}
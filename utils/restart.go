@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFdsEnv tells a restarted keploy how many listening sockets it inherited. They
+// are passed as the file descriptors following stderr, starting at 3.
+const listenFdsEnv = "KEPLOY_LISTEN_FDS"
+
+// firstInheritedFd is the first file descriptor of the inherited listeners.
+const firstInheritedFd = 3
+
+// errNoLogger is returned by GracefulRestart when no logger was registered with SetLogger.
+var errNoLogger = errors.New("logger is not set")
+
+var (
+	restartListenersMu sync.Mutex
+	// restartListeners are the listeners handed over to the new process on a restart.
+	restartListeners []net.Listener
+)
+
+// RegisterRestartListener registers a listener to hand over to the new process on a
+// GracefulRestart, so that it keeps accepting connections during the restart. Only
+// TCP and unix listeners can be handed over.
+func RegisterRestartListener(ln net.Listener) {
+	restartListenersMu.Lock()
+	defer restartListenersMu.Unlock()
+	restartListeners = append(restartListeners, ln)
+}
+
+// InheritedListeners returns the listeners handed over by the process which restarted
+// keploy, in their registration order, and none when keploy wasn't restarted. Servers
+// should use them instead of listening again on the same addresses.
+func InheritedListeners() ([]net.Listener, error) {
+	value := os.Getenv(listenFdsEnv)
+	if value == "" {
+		return nil, nil
+	}
+	// The listeners must not be handed over again to the processes keploy starts.
+	if err := os.Unsetenv(listenFdsEnv); err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid %s %q", listenFdsEnv, value)
+	}
+
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		file := os.NewFile(uintptr(firstInheritedFd+i), "listener-"+strconv.Itoa(i))
+		ln, err := net.FileListener(file)
+		// FileListener duplicates the descriptor, the inherited one isn't needed anymore.
+		SafeClose(currentLogger(), file, "the inherited listener file")
+		if err != nil {
+			return nil, fmt.Errorf("failed to use the inherited listener %d: %w", i, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// RestartPlan describes the process started by GracefulRestart.
+type RestartPlan struct {
+	// Path is the keploy binary, usually the one just installed by the update.
+	Path string
+	Args []string
+	Env  []string
+	// Files are the listeners handed over, passed as the file descriptors 3 and up.
+	Files []*os.File
+}
+
+// Close closes the files of the plan, the started process holds its own copies.
+func (p RestartPlan) Close() {
+	for _, file := range p.Files {
+		SafeClose(currentLogger(), file, "the listener file")
+	}
+}
+
+// registeredRestartListeners returns the listeners registered with RegisterRestartListener.
+func registeredRestartListeners() []net.Listener {
+	restartListenersMu.Lock()
+	defer restartListenersMu.Unlock()
+	return append([]net.Listener(nil), restartListeners...)
+}
+
+// planRestart assembles the command restarting keploy with the same arguments and
+// handing over the given listeners.
+func planRestart(listeners []net.Listener) (RestartPlan, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return RestartPlan{}, fmt.Errorf("failed to find the keploy binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	plan := RestartPlan{Path: path, Args: os.Args}
+	for _, ln := range listeners {
+		filer, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			plan.Close()
+			return RestartPlan{}, fmt.Errorf("the listener on %s can't be handed over", ln.Addr())
+		}
+		file, err := filer.File()
+		if err != nil {
+			plan.Close()
+			return RestartPlan{}, fmt.Errorf("failed to hand over the listener on %s: %w", ln.Addr(), err)
+		}
+		plan.Files = append(plan.Files, file)
+	}
+
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, listenFdsEnv+"=") {
+			plan.Env = append(plan.Env, kv)
+		}
+	}
+	if len(plan.Files) > 0 {
+		plan.Env = append(plan.Env, listenFdsEnv+"="+strconv.Itoa(len(plan.Files)))
+	}
+	return plan, nil
+}
@@ -0,0 +1,46 @@
+//go:build !windows
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"go.uber.org/zap"
+)
+
+// GracefulRestart restarts keploy without dropping connections, e.g. after UpdateBinary
+// replaced the binary. A new process is started with the same arguments and inherits
+// the listeners registered with RegisterRestartListener, see InheritedListeners; the
+// current process then stops, draining its in-flight work.
+func GracefulRestart(ctx context.Context) error {
+	logger := currentLogger()
+	if logger == nil {
+		return errNoLogger
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	plan, err := planRestart(registeredRestartListeners())
+	if err != nil {
+		return err
+	}
+	defer plan.Close()
+
+	cmd := exec.Command(plan.Path, plan.Args[1:]...)
+	cmd.Env = plan.Env
+	cmd.ExtraFiles = plan.Files
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start the new keploy process: %w", err)
+	}
+	logger.Info("started the new keploy process", zap.Int("pid", cmd.Process.Pid), zap.Int("listeners", len(plan.Files)))
+	if err := cmd.Process.Release(); err != nil {
+		LogError(logger, err, "failed to release the new keploy process")
+	}
+
+	return StopWithReason(logger, ReasonRestart, "restarting after an update")
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPlanRestart(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows can't hand listeners over")
+	}
+	t.Setenv(listenFdsEnv, "7")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	plan, err := planRestart([]net.Listener{ln})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(plan.Close)
+	if len(plan.Files) != 1 {
+		t.Fatalf("plan.Files = %v, want the listener handed over", plan.Files)
+	}
+	var fds []string
+	for _, kv := range plan.Env {
+		if strings.HasPrefix(kv, listenFdsEnv+"=") {
+			fds = append(fds, kv)
+		}
+	}
+	if !slices.Equal(fds, []string{listenFdsEnv + "=1"}) {
+		t.Fatalf("%s in the environment = %v, want only the handed over count", listenFdsEnv, fds)
+	}
+}
+
+func TestInheritedListeners(t *testing.T) {
+	t.Setenv(listenFdsEnv, "")
+	if listeners, err := InheritedListeners(); err != nil || listeners != nil {
+		t.Fatalf("InheritedListeners() = %v, %v, want none without a restart", listeners, err)
+	}
+	t.Setenv(listenFdsEnv, "many")
+	if _, err := InheritedListeners(); err == nil {
+		t.Fatal("InheritedListeners() accepted an invalid listener count")
+	}
+}
+
+func TestGracefulRestartWithoutLogger(t *testing.T) {
+	t.Cleanup(ResetForTesting)
+	if err := GracefulRestart(context.Background()); !errors.Is(err, errNoLogger) {
+		t.Fatalf("GracefulRestart() error = %v, want errNoLogger", err)
+	}
+}
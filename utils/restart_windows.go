@@ -0,0 +1,44 @@
+//go:build windows
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"go.uber.org/zap"
+)
+
+// GracefulRestart restarts keploy, e.g. after UpdateBinary replaced the binary. Windows
+// can't hand listening sockets over to another process, so keploy is stopped first and
+// the new process is started afterwards: connections are refused in between.
+func GracefulRestart(ctx context.Context) error {
+	logger := currentLogger()
+	if logger == nil {
+		return errNoLogger
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The listeners are closed by the stop, the new process listens again.
+	plan, err := planRestart(nil)
+	if err != nil {
+		return err
+	}
+
+	if err := StopWithReason(logger, ReasonRestart, "restarting after an update"); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(plan.Path, plan.Args[1:]...)
+	cmd.Env = plan.Env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start the new keploy process: %w", err)
+	}
+	logger.Info("started the new keploy process", zap.Int("pid", cmd.Process.Pid))
+	return cmd.Process.Release()
+}
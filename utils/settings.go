@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Settings is the typed view of the well-known keploy user settings.
+type Settings struct {
+	// UpdatePref tells whether keploy should check for updates (update_pref=yes|no).
+	UpdatePref bool
+	// LogLevel is the default log level (log_level=debug|info|warn|error).
+	LogLevel string
+	// ReleaseChannel is the release channel used for updates (release_channel=stable|beta).
+	ReleaseChannel string
+	// UpdateCheckInterval is the minimum time between two update checks (update_check_interval).
+	UpdateCheckInterval time.Duration
+}
+
+// DefaultSettings returns the settings used when a key is absent from the config.
+func DefaultSettings() *Settings {
+	return &Settings{
+		UpdatePref:          true,
+		LogLevel:            "info",
+		ReleaseChannel:      "stable",
+		UpdateCheckInterval: 24 * time.Hour,
+	}
+}
+
+// LoadSettings reads the keploy user settings file and returns the validated settings.
+func LoadSettings() (*Settings, error) {
+	config, err := GlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+	return ParseSettings(config)
+}
+
+// ParseSettings fills the settings from a parsed config, using the defaults for absent keys.
+func ParseSettings(config map[string]string) (*Settings, error) {
+	settings := DefaultSettings()
+
+	if value, ok := config["update_pref"]; ok {
+		enabled, valid := parseYesNo(value)
+		if !valid {
+			return nil, fmt.Errorf("invalid value %q for update_pref: expected yes or no", value)
+		}
+		settings.UpdatePref = enabled
+	}
+
+	if value, ok := config["log_level"]; ok {
+		switch strings.ToLower(value) {
+		case "debug", "info", "warn", "error":
+			settings.LogLevel = strings.ToLower(value)
+		default:
+			return nil, fmt.Errorf("invalid value %q for log_level: expected one of debug, info, warn, error", value)
+		}
+	}
+
+	if value, ok := config["release_channel"]; ok {
+		switch strings.ToLower(value) {
+		case "stable", "beta":
+			settings.ReleaseChannel = strings.ToLower(value)
+		default:
+			return nil, fmt.Errorf("invalid value %q for release_channel: expected stable or beta", value)
+		}
+	}
+
+	if value, ok := config["update_check_interval"]; ok {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for update_check_interval: expected a duration such as 24h: %v", value, err)
+		}
+		if interval < 0 {
+			return nil, fmt.Errorf("invalid value %q for update_check_interval: must not be negative", value)
+		}
+		settings.UpdateCheckInterval = interval
+	}
+
+	return settings, nil
+}
+
+// parseYesNo parses the value of a yes/no setting, such as update_pref, which also
+// accepts y/n and true/false. valid is false for any other value.
+func parseYesNo(value string) (enabled, valid bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "y", "true":
+		return true, true
+	case "no", "n", "false":
+		return false, true
+	default:
+		return false, false
+	}
+}
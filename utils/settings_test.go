@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestParseSettingsDefaults(t *testing.T) {
+	settings, err := ParseSettings(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *settings != *DefaultSettings() {
+		t.Fatalf("ParseSettings() = %+v, want the defaults %+v", *settings, *DefaultSettings())
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	settings, err := ParseSettings(map[string]string{
+		"update_pref":           "No",
+		"log_level":             "DEBUG",
+		"release_channel":       "beta",
+		"update_check_interval": "6h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Settings{UpdatePref: false, LogLevel: "debug", ReleaseChannel: "beta", UpdateCheckInterval: 6 * time.Hour}
+	if *settings != want {
+		t.Fatalf("ParseSettings() = %+v, want %+v", *settings, want)
+	}
+}
+
+func TestParseSettingsInvalid(t *testing.T) {
+	for key, value := range map[string]string{
+		"update_pref":           "maybe",
+		"log_level":             "verbose",
+		"release_channel":       "nightly",
+		"update_check_interval": "daily",
+	} {
+		if _, err := ParseSettings(map[string]string{key: value}); err == nil {
+			t.Errorf("ParseSettings() accepted %s=%s", key, value)
+		}
+	}
+	if _, err := ParseSettings(map[string]string{"update_check_interval": "-1h"}); err == nil {
+		t.Error("ParseSettings() accepted a negative update_check_interval")
+	}
+}
+
+func TestUpdatePrefParsing(t *testing.T) {
+	tests := []struct {
+		value          string
+		enabled, valid bool
+	}{
+		{value: "yes", enabled: true, valid: true},
+		{value: "y", enabled: true, valid: true},
+		{value: "TRUE", enabled: true, valid: true},
+		{value: "no", enabled: false, valid: true},
+		{value: "n", enabled: false, valid: true},
+		{value: "false", enabled: false, valid: true},
+		{value: "maybe", enabled: false, valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if enabled, valid := parseYesNo(tt.value); enabled != tt.enabled || valid != tt.valid {
+				t.Fatalf("parseYesNo() = %v, %v, want %v, %v", enabled, valid, tt.enabled, tt.valid)
+			}
+			if !tt.valid {
+				return
+			}
+
+			useKeployHome(t, "update_pref="+tt.value+"\n")
+			enabled, err := checkUpdatePreference(context.Background(), zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+			settings, err := ParseSettings(map[string]string{"update_pref": tt.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if enabled != tt.enabled || settings.UpdatePref != tt.enabled {
+				t.Fatalf("checkUpdatePreference() = %v, ParseSettings() UpdatePref = %v, want %v", enabled, settings.UpdatePref, tt.enabled)
+			}
+		})
+	}
+}
+
+func TestUpdatePrefEnvOverride(t *testing.T) {
+	t.Setenv(ConfigEnvVar("update_pref"), "false")
+	useKeployHome(t, "update_pref=yes\n")
+
+	if enabled, known := storedUpdatePreference(zap.NewNop()); enabled || !known {
+		t.Fatalf("storedUpdatePreference() = %v, %v, want false, true", enabled, known)
+	}
+}
+
+func TestSavePreferenceEnvOverride(t *testing.T) {
+	t.Setenv(ConfigEnvVar("update_pref"), "no")
+	useKeployHome(t, "update_pref=yes\n")
+
+	enabled, err := checkUpdatePreference(context.Background(), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled {
+		t.Fatal("checkUpdatePreference() = true, want KEPLOY_UPDATE_PREF=no to win over the config")
+	}
+
+	if err := savePreference(zap.NewNop(), false); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(KeployConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "update_pref=yes\n" {
+		t.Fatalf("savePreference() wrote %q with KEPLOY_UPDATE_PREF set, want the config untouched", data)
+	}
+}
+
+func TestUpdatePrefDefault(t *testing.T) {
+	useKeployHome(t, "")
+
+	settings, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tests don't run in a terminal, nobody can answer the prompt.
+	enabled, err := checkUpdatePreference(context.Background(), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled != settings.UpdatePref {
+		t.Fatalf("checkUpdatePreference() = %v, want the update_pref default %v", enabled, settings.UpdatePref)
+	}
+
+	entries, err := EffectiveConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Key == "update_pref" {
+			if got, _ := parseYesNo(entry.Value); got != settings.UpdatePref || entry.Source != SourceDefault {
+				t.Fatalf("EffectiveConfig() update_pref = %q from %q, want the default %v", entry.Value, entry.Source, settings.UpdatePref)
+			}
+			return
+		}
+	}
+	t.Fatal("EffectiveConfig() has no update_pref")
+}
@@ -0,0 +1,83 @@
+//go:build !windows
+
+package utils
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSignalLoggedNotPrinted(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	SetLogger(zap.New(core))
+	t.Cleanup(func() { SetLogger(nil) })
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	t.Cleanup(func() { os.Stdout = stdout })
+
+	ctx := NewCtx()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the global context is not canceled after the signal")
+	}
+
+	os.Stdout = stdout
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	printed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(printed) != 0 {
+		t.Fatalf("stdout = %q, want nothing printed", printed)
+	}
+	if logs.FilterMessage("Signal received, canceling context...").Len() != 1 {
+		t.Fatalf("logs = %v, want the signal logged", logs.All())
+	}
+}
+
+func TestNewCtxNoSignals(t *testing.T) {
+	t.Cleanup(ResetForTesting)
+	// The host application owns the signal handling.
+	host := make(chan os.Signal, 1)
+	signal.Notify(host, syscall.SIGTERM)
+	t.Cleanup(func() { signal.Stop(host) })
+
+	ctx, _ := NewCtxNoSignals()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-host:
+	case <-time.After(time.Second):
+		t.Fatal("the host didn't receive the signal")
+	}
+	if ctx.Err() != nil {
+		t.Fatal("the context is canceled by a signal handled by the host")
+	}
+
+	if err := Stop(zap.NewNop(), "host shutdown"); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("the context is not canceled by Stop")
+	}
+}
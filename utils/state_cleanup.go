@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// staleStateFile is a kind of leftover state file: a glob pattern in a directory, and
+// the age from which the matching files are considered stale.
+type staleStateFile struct {
+	dir     func() string
+	pattern string
+	maxAge  time.Duration
+}
+
+// staleStateFiles lists the leftover state files removed by CleanupStateFiles.
+var staleStateFiles = []staleStateFile{
+	// Temporary files of a config write interrupted by a crash.
+	{BaseDir, keployConfigFile + ".tmp-*", time.Hour},
+	// Probes of CanWriteConfig interrupted by a crash.
+	{BaseDir, ".keploy-write-check-*", time.Hour},
+	// Backup of a corrupted config made by RepairConfig.
+	{BaseDir, keployConfigFile + ".corrupt", 30 * 24 * time.Hour},
+	{BaseDir, releaseCacheFile, 30 * 24 * time.Hour},
+	// Partial updater downloads, keploy-<version>-<asset>.partial, the recent ones can
+	// still be resumed.
+	{func() string { return StatePath(DownloadDir) }, "keploy-*-*.partial", 7 * 24 * time.Hour},
+}
+
+// CleanupStateFiles removes the stale files accumulating in the keploy state: temporary
+// config files, old config backups, an outdated release cache and abandoned partial
+// update downloads. The live config is never removed. It keeps going when a file can't
+// be removed and returns the removed paths along with the first error.
+func CleanupStateFiles() (removed []string, err error) {
+	live, _ := filepath.Abs(KeployConfigPath())
+	now := clock.Now()
+	for _, kind := range staleStateFiles {
+		matches, globErr := filepath.Glob(filepath.Join(kind.dir(), kind.pattern))
+		if globErr != nil {
+			if err == nil {
+				err = globErr
+			}
+			continue
+		}
+		for _, path := range matches {
+			if abs, _ := filepath.Abs(path); abs == live {
+				continue
+			}
+			info, statErr := os.Lstat(path)
+			if statErr != nil || info.IsDir() || now.Sub(info.ModTime()) < kind.maxAge {
+				continue
+			}
+			if rmErr := os.Remove(path); rmErr != nil {
+				if err == nil && !os.IsNotExist(rmErr) {
+					err = rmErr
+				}
+				continue
+			}
+			removed = append(removed, path)
+		}
+	}
+	return removed, err
+}
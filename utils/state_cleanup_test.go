@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCleanupStateFiles(t *testing.T) {
+	home := useKeployHome(t, "update_pref=yes\n")
+	downloads := filepath.Join(home, DownloadDir)
+	if err := os.MkdirAll(downloads, 0700); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	seed := func(path string, modTime time.Time) string {
+		t.Helper()
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	stale := []string{
+		seed(filepath.Join(home, keployConfigFile+".tmp-123"), old),
+		seed(filepath.Join(home, keployConfigFile+".corrupt"), old),
+		seed(filepath.Join(downloads, "keploy-v1.2.0-keploy_linux_amd64.tar.gz.partial"), old),
+	}
+	kept := []string{
+		seed(KeployConfigPath(), old),
+		// A recent partial download can still be resumed.
+		seed(filepath.Join(downloads, "keploy-v1.3.0-keploy_linux_amd64.tar.gz.partial"), time.Now()),
+	}
+
+	removed, err := CleanupStateFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(removed)
+	sort.Strings(stale)
+	if len(removed) != len(stale) {
+		t.Fatalf("CleanupStateFiles() removed %v, want %v", removed, stale)
+	}
+	for i := range stale {
+		if removed[i] != stale[i] {
+			t.Fatalf("CleanupStateFiles() removed %v, want %v", removed, stale)
+		}
+	}
+	for _, path := range kept {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was removed: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ANSI escape codes used to highlight the messages printed on a terminal.
+const (
+	ansiYellow = "\033[33m"
+	ansiBold   = "\033[1m"
+	ansiReset  = "\033[0m"
+)
+
+// IsTerminal reports whether f is a terminal. Output piped to another program or a
+// file must stay plain text, and a prompt can't be answered when stdin isn't a terminal.
+func IsTerminal(f *os.File) bool {
+	return f != nil && term.IsTerminal(int(f.Fd()))
+}
+
+// colorize wraps s in the ANSI color code when stdout is a terminal and NO_COLOR is unset.
+func colorize(s, color string) string {
+	if os.Getenv("NO_COLOR") != "" || !IsTerminal(os.Stdout) {
+		return s
+	}
+	return color + s + ansiReset
+}
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"os"
+	"testing"
+)
+
+func TestColorizeNotTerminal(t *testing.T) {
+	// The tests write to a pipe or a file, never to a terminal.
+	if IsTerminal(os.Stdout) {
+		t.Skip("stdout is a terminal")
+	}
+	if got := colorize("keploy", ansiBold); got != "keploy" {
+		t.Fatalf("colorize() = %q, want plain text when stdout isn't a terminal", got)
+	}
+	if IsTerminal(nil) {
+		t.Fatal("IsTerminal(nil) = true")
+	}
+}
@@ -0,0 +1,1018 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultUpdateCheckTimeout bounds the whole update check so that it
+// never delays the startup of a command for longer.
+const defaultUpdateCheckTimeout = 10 * time.Second
+
+// CheckForUpdate checks whether a newer keploy release is available and warns the user.
+// The whole check, the fetch of the latest release as well as the prompts and saving
+// their answers, is bounded by the update_check_timeout setting (10s by default); when
+// the deadline passes, the check is abandoned and the command continues.
+// It only returns an error, wrapping ErrMandatoryUpdate, when the running version is
+// below the minimum supported one and keploy must not be used until it is updated.
+func CheckForUpdate(ctx context.Context, logger *zap.Logger) error {
+	timeout := updateCheckTimeout(logger)
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	autoUpdate, err := checkForUpdates(checkCtx, logger)
+	if errors.Is(err, ErrMandatoryUpdate) {
+		return err
+	}
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(checkCtx.Err(), context.DeadlineExceeded) {
+			updateTracer(logger)("skipped: timed out after " + timeout.String())
+			err = fmt.Errorf("update check timed out after %s: %w", timeout, err)
+		}
+		logger.Debug("failed to check for updates", zap.Error(err))
+	}
+	if autoUpdate != "" {
+		// The download isn't bounded by the update check timeout.
+		runAutoUpdate(ctx, logger, autoUpdate)
+	}
+	return nil
+}
+
+// awaitUpdateStep runs a step of the update check which may block, e.g. on stdin, and
+// returns its result, or ctx's error once ctx is done. An abandoned step keeps running
+// in the background, the update check doesn't wait for it.
+func awaitUpdateStep[T any](ctx context.Context, step func() T) (T, error) {
+	result := make(chan T, 1)
+	go func() { result <- step() }()
+	select {
+	case v := <-result:
+		return v, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// updateCheckTimeout returns the configured update check deadline.
+func updateCheckTimeout(logger *zap.Logger) time.Duration {
+	config, err := GlobalConfig()
+	if err != nil {
+		return defaultUpdateCheckTimeout
+	}
+	value, ok := config["update_check_timeout"]
+	if !ok {
+		return defaultUpdateCheckTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		logger.Warn("invalid update_check_timeout in the keploy config, using the default", zap.String("value", value), zap.Duration("default", defaultUpdateCheckTimeout))
+		return defaultUpdateCheckTimeout
+	}
+	return timeout
+}
+
+// checkForUpdates checks for a newer release and tells the user about it. It returns the
+// version to install when auto_update allows installing it without asking.
+func checkForUpdates(ctx context.Context, logger *zap.Logger) (string, error) {
+	trace := updateTracer(logger)
+	if _, err := ParsedVersion(); err != nil {
+		trace("skipped: invalid current version", zap.Error(err))
+		return "", nil
+	}
+	jsonOutput := isJSONOutput()
+	// The minimum version known from the last fetch is enforced before the preference
+	// and the daily check, which can't make keploy skip a mandatory update.
+	if status, ok := cachedMandatoryStatus(); ok {
+		trace("mandatory: " + status.Current + " < minimum version " + status.MinimumVersion + " (cached)")
+		return "", reportMandatoryUpdate(status, jsonOutput)
+	}
+
+	var enabled bool
+	var err error
+	if jsonOutput {
+		// Automation can't answer a prompt, an unset preference gets the default.
+		var known bool
+		if enabled, known = storedUpdatePreference(logger); !known {
+			enabled = DefaultSettings().UpdatePref
+		}
+	} else {
+		enabled, err = checkUpdatePreference(ctx, logger)
+	}
+	if err != nil {
+		trace("skipped: failed to read the update preference", zap.Error(err))
+		return "", err
+	}
+	if !enabled {
+		trace("skipped: update_pref=no")
+		return "", nil
+	}
+
+	// A snoozed check doesn't fetch anything, only automation still gets the status.
+	if until, ok := snoozedUntil(); ok && !jsonOutput {
+		trace("skipped: snoozed until " + until.Format(time.RFC3339))
+		return "", nil
+	}
+	daily := !jsonOutput && dailyUpdateCheckEnabled()
+	if daily {
+		// The lock is held until the check is recorded, so that concurrent invocations
+		// can't both check.
+		unlock, locked := lockDailyUpdateCheck(logger)
+		if !locked {
+			trace("skipped: another keploy process is checking for updates")
+			return "", nil
+		}
+		defer unlock()
+		if checkedToday() {
+			trace("skipped: already checked today")
+			return "", nil
+		}
+	}
+	if cache, fresh := freshReleaseCache(); fresh && !jsonOutput {
+		trace("skipped: cache fresh, next check at " + cache.nextCheck(releaseCacheTTL()).Format(time.RFC3339))
+		return "", nil
+	}
+
+	status, err := findUpdate(ctx, logger, trace)
+	if err != nil {
+		return "", err
+	}
+	if daily {
+		recordDailyUpdateCheck(logger)
+	}
+	if status.Mandatory {
+		return "", reportMandatoryUpdate(status, jsonOutput)
+	}
+	if jsonOutput {
+		return "", printUpdateStatusJSON(status)
+	}
+	if !status.UpdateAvailable {
+		return "", nil
+	}
+	if autoUpdateAllowed(autoUpdateLevel(logger), status.Current, status.Latest) && currentAutoUpdater() != nil {
+		trace("auto update: " + status.Current + " -> " + status.Latest + " allowed by auto_update")
+		return status.Latest, nil
+	}
+	prompt := UpdatePrompt{
+		Current:      status.Current,
+		Latest:       status.Latest,
+		Changelog:    status.Changelog,
+		DownloadSize: status.DownloadSize,
+		Default:      DecisionRemindLater,
+	}
+	decision, err := awaitUpdateStep(ctx, func() Decision { return currentUpdatePrompter()(prompt) })
+	if err != nil {
+		trace("skipped: no answer to the prompt before the deadline")
+		return "", err
+	}
+	trace("decision: " + decision.String())
+	applyErr, err := awaitUpdateStep(ctx, func() error { return applyUpdateDecision(logger, prompt, decision) })
+	if err != nil {
+		return "", err
+	}
+	return "", applyErr
+}
+
+// UpdateStatus is the result of an update check.
+type UpdateStatus struct {
+	Current         string `json:"current"`
+	Latest          string `json:"latest"`
+	UpdateAvailable bool   `json:"update_available"`
+	// Mandatory is true when the current version is below MinimumVersion.
+	Mandatory      bool   `json:"mandatory"`
+	MinimumVersion string `json:"minimum_version,omitempty"`
+	// Changelog holds the release notes of the latest release.
+	Changelog string `json:"-"`
+	// DownloadSize is the size in bytes of the archive of the update, 0 when unknown.
+	DownloadSize int64 `json:"download_size,omitempty"`
+}
+
+// Decision is the answer of the user to an update prompt.
+type Decision int
+
+const (
+	// DecisionRemindLater does nothing, the update is offered again on the next check.
+	DecisionRemindLater Decision = iota
+	// DecisionUpdate shows how to install the update.
+	DecisionUpdate
+	// DecisionSkipVersion stops offering the latest version, see AddSkippedVersion.
+	DecisionSkipVersion
+	// DecisionDisableChecks turns the update checks off by saving update_pref=no.
+	DecisionDisableChecks
+	// DecisionSnooze silences the update notifications for a week, see SnoozeUpdateChecks.
+	DecisionSnooze
+)
+
+func (d Decision) String() string {
+	switch d {
+	case DecisionRemindLater:
+		return "remind later"
+	case DecisionUpdate:
+		return "update"
+	case DecisionSkipVersion:
+		return "skip version"
+	case DecisionDisableChecks:
+		return "disable checks"
+	case DecisionSnooze:
+		return "snooze"
+	default:
+		return fmt.Sprintf("Decision(%d)", int(d))
+	}
+}
+
+// UpdatePrompt holds what is shown to the user when a newer version is available, so
+// that embedders, e.g. a TUI, can render the prompt their own way.
+type UpdatePrompt struct {
+	Current   string
+	Latest    string
+	Changelog string
+	// DownloadSize is the size in bytes of the archive of the update, 0 when unknown.
+	DownloadSize int64
+	// Default is the decision to use when the user doesn't answer.
+	Default Decision
+}
+
+// UpdatePrompter renders an update prompt and returns the decision of the user.
+type UpdatePrompter func(prompt UpdatePrompt) Decision
+
+var (
+	updatePrompterMu sync.Mutex
+	updatePrompter   UpdatePrompter = promptUpdate
+)
+
+// SetUpdatePrompter replaces the renderer of the update prompt, passing nil restores
+// the default one which prints the upgrade instructions.
+func SetUpdatePrompter(p UpdatePrompter) {
+	updatePrompterMu.Lock()
+	defer updatePrompterMu.Unlock()
+	if p == nil {
+		p = promptUpdate
+	}
+	updatePrompter = p
+}
+
+func currentUpdatePrompter() UpdatePrompter {
+	updatePrompterMu.Lock()
+	defer updatePrompterMu.Unlock()
+	return updatePrompter
+}
+
+// promptUpdate is the default update prompt renderer. It prints the upgrade
+// instructions and reads the decision from stdin. Without a terminal nobody can
+// answer, e.g. in CI, and the default decision is returned so that keploy never blocks.
+func promptUpdate(prompt UpdatePrompt) Decision {
+	logWarning(prompt.Current, prompt.Latest, prompt.Changelog, prompt.DownloadSize)
+	if !IsTerminal(os.Stdin) {
+		return prompt.Default
+	}
+	fmt.Printf("%s [u]pdate, [s]kip %s, snooze for a [w]eek, [d]isable update checks or remind me [l]ater: ", Emoji, DisplayVersion(prompt.Latest))
+	var response string
+	// An error here means an empty line, which falls back to the default.
+	_, _ = fmt.Scanln(&response)
+	return parseUpdateDecision(response, prompt.Default)
+}
+
+// parseUpdateDecision interprets an answer to the update prompt, an empty or unknown
+// answer gives def.
+func parseUpdateDecision(response string, def Decision) Decision {
+	switch strings.ToLower(strings.TrimSpace(response)) {
+	case "u", "update":
+		return DecisionUpdate
+	case "s", "skip":
+		return DecisionSkipVersion
+	case "w", "snooze":
+		return DecisionSnooze
+	case "d", "disable":
+		return DecisionDisableChecks
+	case "l", "later":
+		return DecisionRemindLater
+	default:
+		return def
+	}
+}
+
+// applyUpdateDecision performs the action matching the decision taken on the prompt.
+func applyUpdateDecision(logger *zap.Logger, prompt UpdatePrompt, decision Decision) error {
+	switch decision {
+	case DecisionRemindLater:
+		return nil
+	case DecisionUpdate:
+		fmt.Println("Run `" + updateInstruction(installMethod()) + "` to update to " + DisplayVersion(prompt.Latest))
+		return nil
+	case DecisionSkipVersion:
+		return AddSkippedVersion(logger, prompt.Latest)
+	case DecisionDisableChecks:
+		return savePreference(logger, false)
+	case DecisionSnooze:
+		return SnoozeUpdateChecks(logger, defaultSnoozeDuration)
+	default:
+		return fmt.Errorf("unknown update decision %v", decision)
+	}
+}
+
+func mandatoryUpdateError(status UpdateStatus) error {
+	return fmt.Errorf("%w: %s is no longer supported, the minimum version is %s", ErrMandatoryUpdate, status.Current, status.MinimumVersion)
+}
+
+// UpdateEvent tells that a newer keploy release is available.
+type UpdateEvent struct {
+	Current string
+	Latest  string
+}
+
+// findUpdate fetches the latest release and tells whether it should be offered to the user.
+func findUpdate(ctx context.Context, logger *zap.Logger, trace func(msg string, fields ...zap.Field)) (UpdateStatus, error) {
+	current, err := ParsedVersion()
+	if err != nil {
+		trace("skipped: invalid current version", zap.Error(err))
+		return UpdateStatus{Current: Version}, nil
+	}
+	status := UpdateStatus{Current: current.String()}
+	releaseInfo, err := getLatestRelease(ctx, logger)
+	if err != nil {
+		trace("skipped: failed to fetch the latest release", zap.Error(err))
+		return status, fmt.Errorf("failed to fetch latest GitHub release version: %w", err)
+	}
+	status.Latest = releaseInfo.TagName
+	status.Changelog = releaseInfo.Body
+	if asset, ok := platformAsset(releaseInfo); ok {
+		status.DownloadSize = asset.Size
+	}
+
+	status.MinimumVersion = minimumVersion(releaseInfo.MinimumVersion)
+	if status.MinimumVersion != "" {
+		if cmp, err := CompareVersions(status.Current, status.MinimumVersion); err == nil && cmp < 0 {
+			trace("mandatory: " + status.Current + " < minimum version " + status.MinimumVersion)
+			status.UpdateAvailable = true
+			status.Mandatory = true
+			return status, nil
+		}
+	}
+
+	if IsVersionSkipped(status.Latest) {
+		trace("skipped: version " + status.Latest + " is in skipped_version")
+		return status, nil
+	}
+	cmp, err := CompareVersions(status.Latest, status.Current)
+	if err != nil {
+		trace("skipped: failed to parse the versions", zap.Error(err))
+		return status, nil
+	}
+	if cmp <= 0 {
+		trace("skipped: already on the latest version " + status.Current)
+		return status, nil
+	}
+	if platformAssetMissing(releaseInfo) {
+		trace("skipped: release " + status.Latest + " has no asset for " + runtime.GOOS + "/" + runtime.GOARCH + " yet")
+		return status, nil
+	}
+	trace("offered: " + status.Latest + " > " + status.Current)
+	status.UpdateAvailable = true
+	return status, nil
+}
+
+// cachedMandatoryStatus returns the status of a mandatory update when the running
+// version is below the minimum version of the cached release or the minimum_version
+// setting, which applies without a cache.
+func cachedMandatoryStatus() (UpdateStatus, bool) {
+	current, err := ParsedVersion()
+	if err != nil {
+		return UpdateStatus{}, false
+	}
+	cache, _ := readReleaseCache()
+	minimum := minimumVersion(cache.MinimumVersion)
+	if minimum == "" {
+		return UpdateStatus{}, false
+	}
+	if cmp, err := CompareVersions(current.String(), minimum); err != nil || cmp >= 0 {
+		return UpdateStatus{}, false
+	}
+	latest := cache.TagName
+	if latest == "" {
+		latest = minimum
+	}
+	return UpdateStatus{
+		Current:         current.String(),
+		Latest:          latest,
+		UpdateAvailable: true,
+		Mandatory:       true,
+		MinimumVersion:  minimum,
+		Changelog:       cache.Body,
+	}, true
+}
+
+// reportMandatoryUpdate tells the user about the mandatory update and returns the
+// error stopping keploy.
+func reportMandatoryUpdate(status UpdateStatus, jsonOutput bool) error {
+	if jsonOutput {
+		if err := printUpdateStatusJSON(status); err != nil {
+			return err
+		}
+	} else {
+		logWarning(status.Current, status.Latest, status.Changelog, status.DownloadSize)
+	}
+	return mandatoryUpdateError(status)
+}
+
+// ErrMandatoryUpdate is returned when the running version is older than the minimum
+// supported version, e.g. after a security release.
+var ErrMandatoryUpdate = errors.New("a mandatory keploy update is required")
+
+// minimumVersion returns the minimum supported version: the highest of the one
+// announced by the update endpoint and the minimum_version setting.
+func minimumVersion(announced string) string {
+	configured := GetString("minimum_version")
+	if announced == "" {
+		return configured
+	}
+	if configured == "" {
+		return announced
+	}
+	if cmp, err := CompareVersions(configured, announced); err == nil && cmp > 0 {
+		return configured
+	}
+	return announced
+}
+
+// isJSONOutput reports whether the update status must be printed as JSON, which is
+// set with output_format=json in the keploy config or KEPLOY_OUTPUT_FORMAT=json, see
+// ApplyEnvOverrides.
+func isJSONOutput() bool {
+	return strings.EqualFold(GetString("output_format"), "json")
+}
+
+// printUpdateStatusJSON prints the update status as a single JSON object on stdout.
+func printUpdateStatusJSON(status UpdateStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// StartBackgroundUpdateChecker checks for updates every interval, with a random jitter
+// of up to a tenth of the interval, until ctx is canceled. It never prompts: available
+// updates are sent on the returned channel, which is closed when the checker stops.
+// It suits long running keploy processes where a check at startup is not enough.
+func StartBackgroundUpdateChecker(ctx context.Context, logger *zap.Logger, interval time.Duration) <-chan UpdateEvent {
+	events := make(chan UpdateEvent, 1)
+	go func() {
+		defer close(events)
+		for {
+			wait := interval
+			if jitter := int64(interval / 10); jitter > 0 {
+				wait += time.Duration(rand.Int63n(jitter))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-clock.After(wait):
+			}
+
+			if enabled, known := storedUpdatePreference(logger); known && !enabled {
+				continue
+			}
+			status, err := findUpdate(ctx, logger, updateTracer(logger))
+			if err != nil {
+				logger.Debug("background update check failed", zap.Error(err))
+				continue
+			}
+			if !status.UpdateAvailable {
+				continue
+			}
+			select {
+			case events <- UpdateEvent{Current: status.Current, Latest: status.Latest}:
+			default:
+				// The previous event was not consumed yet, it already tells about an update.
+			}
+		}
+	}()
+	return events
+}
+
+// updateTracer returns a function logging the decisions taken by the update check.
+// It only logs when tracing is enabled with update_trace=true in the keploy config
+// or KEPLOY_UPDATE_TRACE=true, otherwise it does nothing.
+func updateTracer(logger *zap.Logger) func(msg string, fields ...zap.Field) {
+	if GetString("update_trace") != "true" {
+		return func(string, ...zap.Field) {}
+	}
+	return func(msg string, fields ...zap.Field) {
+		logger.Info("update check: "+msg, fields...)
+	}
+}
+
+// checkUpdatePreference returns whether the user wants to be notified about new versions.
+// The update_pref setting, which KEPLOY_UPDATE_PREF overrides, is used when set; otherwise
+// the user is asked once and the answer is saved as update_pref in the keploy config.
+// When nobody can answer, the default of update_pref, see DefaultSettings, is used.
+func checkUpdatePreference(ctx context.Context, logger *zap.Logger) (bool, error) {
+	if enabled, known := storedUpdatePreference(logger); known {
+		return enabled, nil
+	}
+	if _, err := GlobalConfig(); err != nil {
+		return false, err
+	}
+
+	// Nobody can answer the prompt, e.g. in CI, go on with the default without saving it.
+	if !IsTerminal(os.Stdin) {
+		return DefaultSettings().UpdatePref, nil
+	}
+	// Warn before asking rather than after, when the answer can't be saved.
+	if writable, err := CanWriteConfig(); !writable {
+		logger.Warn("the keploy config is not writable, your answer won't be saved and you will be asked again next time", zap.Error(err))
+		return awaitUpdateStep(ctx, promptUpdatePreference)
+	}
+	enabled, err := awaitUpdateStep(ctx, promptUpdatePreference)
+	if err != nil {
+		return false, err
+	}
+	saveErr, err := awaitUpdateStep(ctx, func() error { return savePreference(logger, enabled) })
+	if err != nil {
+		return enabled, err
+	}
+	if err := saveErr; err != nil {
+		if IsReadOnlyError(err) {
+			// The preference is only a convenience, a read-only home (common on CI
+			// images) must not make the command fail.
+			logger.Warn("could not save the update preference as the keploy config is not writable, you will be asked again next time", zap.String("path", KeployConfigPath()))
+			return enabled, nil
+		}
+		return enabled, fmt.Errorf("failed to save the update preference: %w", err)
+	}
+	return enabled, nil
+}
+
+// storedUpdatePreference returns the update preference set in the keploy config or
+// through KEPLOY_UPDATE_PREF. known is false when the user hasn't set any preference yet.
+func storedUpdatePreference(logger *zap.Logger) (enabled, known bool) {
+	config, err := GlobalConfig()
+	if err != nil {
+		return false, false
+	}
+	if value, ok := config["update_pref"]; ok {
+		if enabled, valid := parseYesNo(value); valid {
+			return enabled, true
+		}
+		logger.Warn("invalid update_pref in the keploy config, expected yes or no", zap.String("value", value))
+	}
+	return false, false
+}
+
+// promptUpdatePreference asks the user whether keploy should check for updates.
+// An empty answer gives the update_prompt_default setting, no unless it is set to yes.
+func promptUpdatePreference() bool {
+	def := updatePromptDefault()
+	choices := "[y/N]"
+	if def {
+		choices = "[Y/n]"
+	}
+	fmt.Printf("%s %s %s: ", Emoji, colorize("Do you want Keploy to notify you about new versions?", ansiBold), choices)
+	var response string
+	// An error here means an empty line, which falls back to the default.
+	_, _ = fmt.Scanln(&response)
+	return parsePromptAnswer(response, def)
+}
+
+// updatePromptDefault returns the answer used when the update prompt is left empty.
+func updatePromptDefault() bool {
+	enabled, _ := parseYesNo(GetString("update_prompt_default"))
+	return enabled
+}
+
+// parsePromptAnswer interprets a yes/no answer, an empty or unknown answer gives def.
+func parsePromptAnswer(response string, def bool) bool {
+	switch strings.ToLower(strings.TrimSpace(response)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
+
+// savePreference persists the update preference in the keploy config. Nothing is
+// written when the preference is set through KEPLOY_UPDATE_PREF.
+func savePreference(logger *zap.Logger, enabled bool) error {
+	if _, ok := os.LookupEnv(ConfigEnvVar("update_pref")); ok {
+		return nil
+	}
+	config, err := readLocalKeployConfig(logger)
+	if err != nil {
+		return err
+	}
+	config["update_pref"] = "no"
+	if enabled {
+		config["update_pref"] = "yes"
+	}
+	return WriteKeployConfig(logger, config)
+}
+
+// defaultUpdateURL is the GitHub API endpoint of the latest keploy release.
+const defaultUpdateURL = "https://api.github.com/repos/keploy/keploy/releases/latest"
+
+// UpdateURL returns the endpoint used to fetch the latest release. It can be
+// overridden with the update_url key of the keploy config.
+func UpdateURL() string {
+	if config, err := GlobalConfig(); err == nil && config["update_url"] != "" {
+		return config["update_url"]
+	}
+	return defaultUpdateURL
+}
+
+// UpdateURLs returns the endpoints to fetch the latest release from, in the order they
+// are tried. The update_urls key of the keploy config lists mirrors to fall back to when
+// the primary endpoint, e.g. GitHub, is blocked; without it UpdateURL is used alone.
+func UpdateURLs() []string {
+	if urls := GetStringSlice("update_urls", ""); len(urls) > 0 {
+		return urls
+	}
+	return []string{UpdateURL()}
+}
+
+// setUpdateRequestHeaders sets the headers expected by the GitHub releases API.
+func setUpdateRequestHeaders(req *http.Request) {
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "keploy/"+Version)
+}
+
+// CheckUpdateConnectivity checks that the update endpoint can be reached, through any
+// configured proxy, with a lightweight HEAD request. The returned error describes what
+// failed: DNS resolution, the proxy, the TLS handshake, a timeout or rate limiting.
+func CheckUpdateConnectivity(ctx context.Context, logger *zap.Logger) error {
+	updateURL := UpdateURL()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, updateURL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid update url %q: %w", updateURL, err)
+	}
+	setUpdateRequestHeaders(req)
+
+	client := HTTPClient(4 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return describeConnectivityError(updateURL, err)
+	}
+	defer SafeClose(logger, resp.Body, "response body")
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0":
+		return fmt.Errorf("the update endpoint %s is rate limiting requests, retry after %s", updateURL, resp.Header.Get("X-RateLimit-Reset"))
+	case resp.StatusCode >= 400:
+		return fmt.Errorf("the update endpoint %s responded with status %d", updateURL, resp.StatusCode)
+	}
+	return nil
+}
+
+// describeConnectivityError wraps a network error with the category of the failure.
+func describeConnectivityError(updateURL string, err error) error {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &opErr) && opErr.Op == "proxyconnect":
+		return fmt.Errorf("failed to connect to the proxy while reaching %s: %w", updateURL, err)
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("failed to resolve the host of %s, check your DNS settings: %w", updateURL, err)
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthErr), errors.As(err, &hostnameErr), errors.As(err, &recordErr):
+		return fmt.Errorf("TLS handshake with %s failed: %w", updateURL, err)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("%w: timed out while reaching %s", ErrGitHubAPIUnresponsive, updateURL)
+	default:
+		return fmt.Errorf("failed to reach %s: %w", updateURL, err)
+	}
+}
+
+// getLatestRelease fetches the latest keploy release, retrying failed fetches as set by
+// network_retries and network_retry_backoff, and stores it in the release cache.
+func getLatestRelease(ctx context.Context, logger *zap.Logger) (GitHubRelease, error) {
+	var release GitHubRelease
+	err := withNetworkRetries(ctx, logger, func() error {
+		var err error
+		release, err = GetLatestGitHubRelease(ctx, logger)
+		return err
+	})
+	if err != nil {
+		return release, err
+	}
+	if err := writeReleaseCache(release); err != nil && logger != nil {
+		logger.Debug("failed to write the release cache", zap.Error(err))
+	}
+	return release, nil
+}
+
+// Levels of the update_prompt_verbosity setting.
+const (
+	VerbosityQuiet   = "quiet"
+	VerbosityNormal  = "normal"
+	VerbosityVerbose = "verbose"
+)
+
+// updatePromptVerbosity returns the update_prompt_verbosity setting, normal by default.
+func updatePromptVerbosity() string {
+	switch verbosity := strings.ToLower(GetString("update_prompt_verbosity")); verbosity {
+	case VerbosityQuiet, VerbosityVerbose:
+		return verbosity
+	default:
+		return VerbosityNormal
+	}
+}
+
+// logWarning tells the user that a newer version of keploy is available, along with
+// the upgrade command matching the way keploy was installed. The update_prompt_verbosity
+// setting makes it a single line (quiet) or adds the release notes (verbose). The
+// download size is shown when known, for users on metered connections.
+func logWarning(currentVersion, latestVersion, changelog string, downloadSize int64) {
+	currentVersion, latestVersion = DisplayVersion(currentVersion), DisplayVersion(latestVersion)
+	instruction := updateInstruction(installMethod())
+	verbosity := updatePromptVerbosity()
+	if verbosity == VerbosityQuiet {
+		fmt.Println(colorize("Keploy "+latestVersion+" is available", ansiYellow) + " (current " + currentVersion + "), run `" + instruction + "` to update")
+		return
+	}
+	fmt.Println(colorize("New version of Keploy is available:", ansiYellow))
+	fmt.Println(currentVersion + " ----> " + colorize(latestVersion, ansiBold))
+	if downloadSize > 0 {
+		fmt.Println("Download size: " + ByteSize(downloadSize).String())
+	}
+	fmt.Println("Run `" + colorize(instruction, ansiBold) + "` to update")
+	if changelog = strings.TrimSpace(changelog); verbosity == VerbosityVerbose && changelog != "" {
+		fmt.Println("\nWhat's new in " + latestVersion + ":\n" + changelog)
+	}
+}
+
+// Install methods of the keploy binary.
+const (
+	InstallMethodScript   = "script"
+	InstallMethodHomebrew = "brew"
+	InstallMethodApt      = "apt"
+	InstallMethodDocker   = "docker"
+)
+
+// installMethod returns how keploy was installed. The install_method key of the keploy
+// config, set at install time, wins; otherwise the method is guessed from the binary path.
+func installMethod() string {
+	if config, err := GlobalConfig(); err == nil && config["install_method"] != "" {
+		return config["install_method"]
+	}
+	if len(os.Getenv("KEPLOY_INDOCKER")) > 0 {
+		return InstallMethodDocker
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return InstallMethodScript
+	}
+	// Package managers usually link the binary into the PATH, the target tells which one.
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	return installMethodFromPath(executable)
+}
+
+// IsPackageManaged reports whether the keploy binary is managed by a package manager,
+// Homebrew or apt, along with the command upgrading it. Such a binary must not be
+// replaced by keploy update, it would conflict with the package manager.
+func IsPackageManaged() (bool, string) {
+	method := installMethod()
+	if method == InstallMethodHomebrew || method == InstallMethodApt {
+		return true, updateInstruction(method)
+	}
+	return false, ""
+}
+
+// installMethodFromPath guesses the install method from the path of the keploy binary.
+func installMethodFromPath(path string) string {
+	switch {
+	case strings.Contains(path, "/Cellar/") || strings.Contains(path, "/homebrew/") || strings.Contains(path, "/linuxbrew/"):
+		return InstallMethodHomebrew
+	case strings.HasPrefix(path, "/usr/bin/"):
+		return InstallMethodApt
+	default:
+		return InstallMethodScript
+	}
+}
+
+// updateInstruction returns the command to upgrade keploy for the given install method.
+func updateInstruction(method string) string {
+	switch method {
+	case InstallMethodHomebrew:
+		return "brew upgrade keploy"
+	case InstallMethodApt:
+		return "sudo apt-get update && sudo apt-get install keploy"
+	case InstallMethodDocker:
+		return "docker pull ghcr.io/keploy/keploy:latest"
+	default:
+		return "keploy update"
+	}
+}
+
+const (
+	// dailyCheckMarkerPrefix prefixes the date-stamped markers of the daily update check.
+	dailyCheckMarkerPrefix = "update-checked-"
+	// dailyCheckLockFile is held by the process running the daily update check.
+	dailyCheckLockFile = "update-check.lock"
+)
+
+// dailyUpdateCheckEnabled reports whether the update_check_daily setting limits the
+// update checks to one per day across all the keploy processes of the machine.
+func dailyUpdateCheckEnabled() bool {
+	return strings.EqualFold(GetString("update_check_daily"), "true")
+}
+
+// dailyCheckMarker returns the marker recording that the update check ran today.
+func dailyCheckMarker() string {
+	return StatePath(dailyCheckMarkerPrefix + clock.Now().Format("2006-01-02"))
+}
+
+// lockDailyUpdateCheck takes the lock of the daily update check, which is created with
+// O_EXCL so that the file system guarantees only one process holds it. ok is false when
+// another process holds it. A lock older than the longest check is left by a process
+// which crashed, it is taken over. Failing to create the lock must not prevent the check.
+func lockDailyUpdateCheck(logger *zap.Logger) (unlock func(), ok bool) {
+	path := StatePath(dailyCheckLockFile)
+	if err := EnsureStateDir(filepath.Dir(path)); err != nil {
+		logger.Debug("failed to create the keploy state directory", zap.Error(err))
+		return func() {}, true
+	}
+	staleAfter := max(time.Minute, 2*updateCheckTimeout(logger))
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			SafeClose(logger, file, "the daily update check lock")
+			return func() {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					logger.Debug("failed to remove the daily update check lock", zap.Error(err))
+				}
+			}, true
+		}
+		if !os.IsExist(err) {
+			logger.Debug("failed to create the daily update check lock", zap.Error(err))
+			return func() {}, true
+		}
+		if info, err := os.Stat(path); err == nil && clock.Now().Sub(info.ModTime()) < staleAfter {
+			return nil, false
+		}
+		_ = os.Remove(path)
+	}
+	return nil, false
+}
+
+// checkedToday reports whether a keploy process of the machine already checked for
+// updates today, see recordDailyUpdateCheck.
+func checkedToday() bool {
+	_, err := os.Stat(dailyCheckMarker())
+	return err == nil
+}
+
+// recordDailyUpdateCheck records that the update check ran today by creating today's
+// marker in the state directory. It is only called once the latest release was fetched,
+// so a failed check is retried by the next invocation. The markers of the previous days
+// are removed.
+func recordDailyUpdateCheck(logger *zap.Logger) {
+	marker := dailyCheckMarker()
+	if err := EnsureStateDir(filepath.Dir(marker)); err != nil {
+		logger.Debug("failed to create the keploy state directory", zap.Error(err))
+		return
+	}
+	if err := os.WriteFile(marker, nil, 0600); err != nil {
+		logger.Debug("failed to create the daily update check marker", zap.Error(err))
+		return
+	}
+
+	if old, err := filepath.Glob(StatePath(dailyCheckMarkerPrefix + "*")); err == nil {
+		for _, path := range old {
+			if path != marker {
+				_ = os.Remove(path)
+			}
+		}
+	}
+}
+
+// snoozeKey holds the time, in RFC 3339 format, until which the update notifications
+// are silenced. Unlike update_pref=no it expires, and unlike skipped_version it silences
+// every version.
+const snoozeKey = "snooze_until"
+
+// defaultSnoozeDuration is how long DecisionSnooze silences the update notifications.
+const defaultSnoozeDuration = 7 * 24 * time.Hour
+
+// SnoozeUpdateChecks silences the update notifications for the given duration.
+func SnoozeUpdateChecks(logger *zap.Logger, d time.Duration) error {
+	config, err := readLocalKeployConfig(logger)
+	if err != nil {
+		return err
+	}
+	config[snoozeKey] = clock.Now().Add(d).UTC().Format(time.RFC3339)
+	return WriteKeployConfig(logger, config)
+}
+
+// snoozedUntil returns the end of the snooze, ok is false when the update notifications
+// are not snoozed. An invalid snooze_until is ignored.
+func snoozedUntil() (until time.Time, ok bool) {
+	value := GetString(snoozeKey)
+	if value == "" {
+		return time.Time{}, false
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil || !clock.Now().Before(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// skippedVersionKey holds the comma separated list of versions the user chose to skip.
+const skippedVersionKey = "skipped_version"
+
+// AddSkippedVersion adds version to the list of skipped versions in the keploy config.
+func AddSkippedVersion(logger *zap.Logger, version string) error {
+	config, err := readLocalKeployConfig(logger)
+	if err != nil {
+		return err
+	}
+	versions := append(splitSkippedVersions(config[skippedVersionKey]), splitSkippedVersions(version)...)
+	config[skippedVersionKey] = JoinConfigList(normalizeSkippedVersions(versions, "v"+Version), defaultListSeparator)
+	return WriteKeployConfig(logger, config)
+}
+
+// IsVersionSkipped reports whether the user chose to skip the given version.
+func IsVersionSkipped(version string) bool {
+	config, err := GlobalConfig()
+	if err != nil {
+		return false
+	}
+	version = "v" + strings.TrimPrefix(strings.TrimSpace(version), "v")
+	for _, skipped := range splitSkippedVersions(config[skippedVersionKey]) {
+		if skipped == version {
+			return true
+		}
+	}
+	return false
+}
+
+// ClearSkippedVersions removes all the skipped versions from the keploy config.
+func ClearSkippedVersions(logger *zap.Logger) error {
+	config, err := readLocalKeployConfig(logger)
+	if err != nil {
+		return err
+	}
+	if _, ok := config[skippedVersionKey]; !ok {
+		return nil
+	}
+	delete(config, skippedVersionKey)
+	return WriteKeployConfig(logger, config)
+}
+
+func splitSkippedVersions(value string) []string {
+	var versions []string
+	for _, v := range SplitConfigList(value, defaultListSeparator) {
+		versions = append(versions, "v"+strings.TrimPrefix(v, "v"))
+	}
+	return versions
+}
+
+// normalizeSkippedVersions sorts and deduplicates the versions and drops the ones
+// older than the current version, since they can never be offered again.
+func normalizeSkippedVersions(versions []string, currentVersion string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, v := range versions {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		if cmp, err := CompareVersions(v, currentVersion); err == nil && cmp < 0 {
+			continue
+		}
+		result = append(result, v)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		cmp, err := CompareVersions(result[i], result[j])
+		if err != nil {
+			return result[i] < result[j]
+		}
+		return cmp < 0
+	})
+	return result
+}
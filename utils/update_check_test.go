@@ -0,0 +1,869 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// useKeployHome points KEPLOY_HOME to a temporary directory holding the given config
+// and reloads the global config from it.
+func useKeployHome(t *testing.T, config string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("KEPLOY_HOME", dir)
+	if config != "" {
+		if err := os.WriteFile(filepath.Join(dir, keployConfigFile), []byte(config), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A config which fails to load is reported by the tests reading it.
+	_ = ReloadConfig()
+	return dir
+}
+
+// useVersion sets the running keploy version for the duration of the test.
+func useVersion(t *testing.T, version string) {
+	t.Helper()
+	previous := Version
+	Version = version
+	t.Cleanup(func() { Version = previous })
+}
+
+// useTransport replaces the transport of the http clients for the duration of the test.
+func useTransport(t *testing.T, transport http.RoundTripper) {
+	t.Helper()
+	previous := http.DefaultTransport
+	http.DefaultTransport = transport
+	t.Cleanup(func() { http.DefaultTransport = previous })
+}
+
+// releaseTransport answers every request with the release.
+type releaseTransport GitHubRelease
+
+func (r releaseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := json.Marshal(GitHubRelease(r))
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// slowTransport never answers, a request only ends when its context is done.
+type slowTransport struct{}
+
+func (slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestCheckForUpdateSlowServerDeadline(t *testing.T) {
+	useKeployHome(t, "update_pref=yes\nupdate_check_timeout=100ms\n")
+	useTransport(t, slowTransport{})
+
+	start := time.Now()
+	CheckForUpdate(context.Background(), zap.NewNop())
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("CheckForUpdate() took %s, want it to give up after the 100ms deadline", elapsed)
+	}
+}
+
+func TestCheckForUpdateBlockedPromptDeadline(t *testing.T) {
+	useVersion(t, "1.1.0")
+	useKeployHome(t, "update_pref=yes\nupdate_check_timeout=100ms\n")
+	useTransport(t, releaseTransport{TagName: "v1.2.0"})
+	unblock := make(chan struct{})
+	t.Cleanup(func() { close(unblock) })
+	SetUpdatePrompter(func(prompt UpdatePrompt) Decision {
+		<-unblock
+		return prompt.Default
+	})
+	t.Cleanup(func() { SetUpdatePrompter(nil) })
+
+	start := time.Now()
+	CheckForUpdate(context.Background(), zap.NewNop())
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("CheckForUpdate() took %s, want it to give up on the prompt after the 100ms deadline", elapsed)
+	}
+}
+
+func TestAwaitUpdateStepDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	start := time.Now()
+	_, err := awaitUpdateStep(ctx, func() bool {
+		<-unblock
+		return true
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("awaitUpdateStep() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("awaitUpdateStep() took %s, want it to give up on the blocked step after the 100ms deadline", elapsed)
+	}
+}
+
+func TestSkippedVersions(t *testing.T) {
+	useVersion(t, "1.1.0")
+	useKeployHome(t, "skipped_version=1.0.0, v1.3.0\n")
+	logger := zap.NewNop()
+
+	if err := AddSkippedVersion(logger, "1.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddSkippedVersion(logger, "v1.3.0"); err != nil {
+		t.Fatal(err)
+	}
+	config, err := ReadKeployConfig(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sorted, deduplicated, and without the version older than the running one.
+	if got := config[skippedVersionKey]; got != "v1.2.0,v1.3.0" {
+		t.Fatalf("skipped_version = %q, want %q", got, "v1.2.0,v1.3.0")
+	}
+	if !IsVersionSkipped("1.2.0") || IsVersionSkipped("v1.4.0") {
+		t.Fatal("IsVersionSkipped() doesn't match the skipped versions")
+	}
+
+	if err := ClearSkippedVersions(logger); err != nil {
+		t.Fatal(err)
+	}
+	if IsVersionSkipped("v1.2.0") {
+		t.Fatal("v1.2.0 is still skipped after ClearSkippedVersions")
+	}
+}
+
+func TestNormalizeSkippedVersions(t *testing.T) {
+	got := normalizeSkippedVersions([]string{"v1.10.0", "v1.2.0", "v1.10.0", "v0.9.0"}, "v1.0.0")
+	if want := []string{"v1.2.0", "v1.10.0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("normalizeSkippedVersions() = %v, want %v", got, want)
+	}
+}
+
+func TestCheckForUpdatesTrace(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		release GitHubRelease
+		want    string
+	}{
+		{"disabled", "update_pref=no\n", GitHubRelease{TagName: "v1.2.0"}, "skipped: update_pref=no"},
+		{"skipped version", "update_pref=yes\nskipped_version=v1.2.0\n", GitHubRelease{TagName: "v1.2.0"}, "skipped: version v1.2.0 is in skipped_version"},
+		{"latest", "update_pref=yes\n", GitHubRelease{TagName: "v1.1.0"}, "skipped: already on the latest version v1.1.0"},
+		{"offered", "update_pref=yes\n", GitHubRelease{TagName: "v1.2.0"}, "offered: v1.2.0 > v1.1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useVersion(t, "1.1.0")
+			useKeployHome(t, tt.config+"update_trace=true\n")
+			useTransport(t, releaseTransport(tt.release))
+			core, logs := observer.New(zapcore.InfoLevel)
+
+			if _, err := checkForUpdates(context.Background(), zap.New(core)); err != nil {
+				t.Fatalf("checkForUpdates() error = %v", err)
+			}
+			if logs.FilterMessage("update check: "+tt.want).Len() != 1 {
+				t.Fatalf("logs = %v, want the trace %q", logs.All(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckForUpdatesTraceFetchFailure(t *testing.T) {
+	useVersion(t, "1.1.0")
+	t.Setenv("KEPLOY_UPDATE_TRACE", "true")
+	useKeployHome(t, "update_pref=yes\n")
+	useTransport(t, slowTransport{})
+	core, logs := observer.New(zapcore.InfoLevel)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := checkForUpdates(ctx, zap.New(core)); err == nil {
+		t.Fatal("checkForUpdates() succeeded without the latest release")
+	}
+	if logs.FilterMessage("update check: skipped: failed to fetch the latest release").Len() != 1 {
+		t.Fatalf("logs = %v, want the fetch failure traced", logs.All())
+	}
+}
+
+func TestCheckForUpdatesNoTrace(t *testing.T) {
+	useKeployHome(t, "update_pref=no\n")
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	if _, err := checkForUpdates(context.Background(), zap.New(core)); err != nil {
+		t.Fatalf("checkForUpdates() error = %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("logs = %v, want no trace unless it is enabled", logs.All())
+	}
+}
+
+func TestInstallMethodFromPath(t *testing.T) {
+	tests := map[string]string{
+		"/opt/homebrew/Cellar/keploy/2.0.0/bin/keploy": InstallMethodHomebrew,
+		"/home/linuxbrew/.linuxbrew/bin/keploy":        InstallMethodHomebrew,
+		"/usr/bin/keploy":                              InstallMethodApt,
+		"/usr/local/bin/keploy":                        InstallMethodScript,
+	}
+	for path, want := range tests {
+		if got := installMethodFromPath(path); got != want {
+			t.Errorf("installMethodFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestInstallMethod(t *testing.T) {
+	useKeployHome(t, "install_method="+InstallMethodHomebrew+"\n")
+	t.Setenv("KEPLOY_INDOCKER", "true")
+	if got := installMethod(); got != InstallMethodHomebrew {
+		t.Fatalf("installMethod() = %q, want the install_method of the config", got)
+	}
+
+	useKeployHome(t, "")
+	if got := installMethod(); got != InstallMethodDocker {
+		t.Fatalf("installMethod() = %q, want %q inside docker", got, InstallMethodDocker)
+	}
+}
+
+func TestUpdateInstruction(t *testing.T) {
+	tests := map[string]string{
+		InstallMethodHomebrew: "brew upgrade keploy",
+		InstallMethodApt:      "sudo apt-get update && sudo apt-get install keploy",
+		InstallMethodDocker:   "docker pull ghcr.io/keploy/keploy:latest",
+		InstallMethodScript:   "keploy update",
+		"unknown":             "keploy update",
+	}
+	for method, want := range tests {
+		if got := updateInstruction(method); got != want {
+			t.Errorf("updateInstruction(%q) = %q, want %q", method, got, want)
+		}
+	}
+}
+
+func TestCheckUpdateConnectivity(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		wantErr string
+	}{
+		{"reachable", http.StatusOK, nil, ""},
+		{"rate limited", http.StatusTooManyRequests, nil, "rate limiting"},
+		{"rate limit exhausted", http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0"}, "rate limiting"},
+		{"server error", http.StatusBadGateway, nil, "status 502"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodHead {
+					t.Errorf("method = %s, want HEAD", r.Method)
+				}
+				for key, value := range tt.headers {
+					w.Header().Set(key, value)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			t.Cleanup(server.Close)
+			useKeployHome(t, "update_url="+server.URL+"\n")
+
+			err := CheckUpdateConnectivity(context.Background(), zap.NewNop())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckUpdateConnectivity() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CheckUpdateConnectivity() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDescribeConnectivityError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"dns", &net.DNSError{Err: "no such host", Name: "api.github.com"}, "resolve the host"},
+		{"proxy", &net.OpError{Op: "proxyconnect", Err: errors.New("connection refused")}, "proxy"},
+		{"tls", x509.UnknownAuthorityError{}, "TLS handshake"},
+		{"other", errors.New("connection reset"), "failed to reach"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := describeConnectivityError(defaultUpdateURL, tt.err); !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("describeConnectivityError() = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+	timeout := describeConnectivityError(defaultUpdateURL, timeoutError{})
+	if !errors.Is(timeout, ErrGitHubAPIUnresponsive) {
+		t.Fatalf("describeConnectivityError() = %v, want ErrGitHubAPIUnresponsive for a timeout", timeout)
+	}
+}
+
+// timeoutError is a net.Error reporting a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// manualClock is a Clock whose timers fire when the test sends on ticks.
+type manualClock struct {
+	ticks chan time.Time
+}
+
+func (c manualClock) Now() time.Time { return time.Now() }
+
+func (c manualClock) After(time.Duration) <-chan time.Time { return c.ticks }
+
+func TestBackgroundUpdateChecker(t *testing.T) {
+	useVersion(t, "1.1.0")
+	useKeployHome(t, "update_pref=yes\n")
+	useTransport(t, releaseTransport{TagName: "v1.2.0"})
+	c := manualClock{ticks: make(chan time.Time)}
+	SetClock(c)
+	t.Cleanup(func() { SetClock(nil) })
+	ctx, cancel := context.WithCancel(context.Background())
+
+	events := StartBackgroundUpdateChecker(ctx, zap.NewNop(), time.Hour)
+	c.ticks <- time.Now()
+	select {
+	case event := <-events:
+		if event.Current != "v1.1.0" || event.Latest != "v1.2.0" {
+			t.Fatalf("event = %+v, want an update from v1.1.0 to v1.2.0", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no update event after the check")
+	}
+
+	cancel()
+	// The channel is closed once the checker stopped using the clock.
+	for range events {
+	}
+}
+
+// captureStdout returns what f prints on stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	printed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(printed)
+}
+
+func TestCheckForUpdatesJSONOutput(t *testing.T) {
+	useVersion(t, "1.1.0")
+	// No update_pref: automation is never prompted.
+	useKeployHome(t, "output_format=json\n")
+	useTransport(t, releaseTransport{TagName: "v1.2.0"})
+
+	var err error
+	printed := captureStdout(t, func() { _, err = checkForUpdates(context.Background(), zap.NewNop()) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status UpdateStatus
+	if err := json.Unmarshal([]byte(printed), &status); err != nil {
+		t.Fatalf("stdout = %q, want the JSON update status: %v", printed, err)
+	}
+	if want := (UpdateStatus{Current: "v1.1.0", Latest: "v1.2.0", UpdateAvailable: true}); status != want {
+		t.Fatalf("status = %+v, want %+v", status, want)
+	}
+}
+
+func TestIsJSONOutput(t *testing.T) {
+	useKeployHome(t, "output_format=text\n")
+	if isJSONOutput() {
+		t.Fatal("isJSONOutput() = true with output_format=text")
+	}
+	// The environment overrides are applied when the config is loaded.
+	t.Setenv("KEPLOY_OUTPUT_FORMAT", "JSON")
+	if err := ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if !isJSONOutput() {
+		t.Fatal("isJSONOutput() = false with KEPLOY_OUTPUT_FORMAT=JSON")
+	}
+}
+
+func TestUpdatePrompterDecision(t *testing.T) {
+	useVersion(t, "1.1.0")
+	useKeployHome(t, "update_pref=yes\n")
+	useTransport(t, releaseTransport{TagName: "v1.2.0", Body: "faster proxy"})
+	var shown UpdatePrompt
+	SetUpdatePrompter(func(prompt UpdatePrompt) Decision {
+		shown = prompt
+		return DecisionSkipVersion
+	})
+	t.Cleanup(func() { SetUpdatePrompter(nil) })
+
+	if _, err := checkForUpdates(context.Background(), zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	if want := (UpdatePrompt{Current: "v1.1.0", Latest: "v1.2.0", Changelog: "faster proxy", Default: DecisionRemindLater}); shown != want {
+		t.Fatalf("prompt = %+v, want %+v", shown, want)
+	}
+	if !IsVersionSkipped("v1.2.0") {
+		t.Fatal("the version is not skipped after DecisionSkipVersion")
+	}
+}
+
+func TestApplyUpdateDecisionDisableChecks(t *testing.T) {
+	useKeployHome(t, "update_pref=yes\n")
+	if err := applyUpdateDecision(zap.NewNop(), UpdatePrompt{Latest: "v1.2.0"}, DecisionDisableChecks); err != nil {
+		t.Fatal(err)
+	}
+	if enabled, known := storedUpdatePreference(zap.NewNop()); !known || enabled {
+		t.Fatalf("update preference = %v (known %v), want the checks disabled", enabled, known)
+	}
+	if err := applyUpdateDecision(zap.NewNop(), UpdatePrompt{}, Decision(42)); err == nil {
+		t.Fatal("applyUpdateDecision() accepted an unknown decision")
+	}
+}
+
+// countingTransport is releaseTransport which also counts the requests served.
+type countingTransport struct {
+	release  GitHubRelease
+	requests atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return releaseTransport(c.release).RoundTrip(req)
+}
+
+func TestCheckForUpdatesCacheFresh(t *testing.T) {
+	useVersion(t, "1.1.0")
+	useKeployHome(t, "update_pref=yes\nupdate_trace=true\n")
+	transport := &countingTransport{release: GitHubRelease{TagName: "v1.2.0"}}
+	useTransport(t, transport)
+	SetUpdatePrompter(func(prompt UpdatePrompt) Decision { return prompt.Default })
+	t.Cleanup(func() { SetUpdatePrompter(nil) })
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	for i := 0; i < 2; i++ {
+		if _, err := checkForUpdates(context.Background(), zap.New(core)); err != nil {
+			t.Fatalf("checkForUpdates() error = %v", err)
+		}
+	}
+	if got := transport.requests.Load(); got != 1 {
+		t.Fatalf("the release was fetched %d times, want once while the cache is fresh", got)
+	}
+	if logs.FilterMessageSnippet("update check: skipped: cache fresh").Len() != 1 {
+		t.Fatalf("logs = %v, want the second check skipped because the cache is fresh", logs.All())
+	}
+}
+
+// serveRelease starts a server answering every request with the release.
+func serveRelease(t *testing.T, release GitHubRelease) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(release)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// usePrompter replaces the update prompt, recording the prompts shown, for the
+// duration of the test.
+func usePrompter(t *testing.T, decision Decision) *[]UpdatePrompt {
+	t.Helper()
+	var prompts []UpdatePrompt
+	SetUpdatePrompter(func(prompt UpdatePrompt) Decision {
+		prompts = append(prompts, prompt)
+		return decision
+	})
+	t.Cleanup(func() { SetUpdatePrompter(nil) })
+	return &prompts
+}
+
+func TestCheckForUpdatesMandatory(t *testing.T) {
+	useVersion(t, "1.0.0")
+	server := serveRelease(t, GitHubRelease{TagName: "v1.2.0", MinimumVersion: "v1.1.0"})
+	useKeployHome(t, "update_pref=yes\nupdate_url="+server.URL+"\n")
+	usePrompter(t, DecisionRemindLater)
+
+	_, err := checkForUpdates(context.Background(), zap.NewNop())
+	if !errors.Is(err, ErrMandatoryUpdate) {
+		t.Fatalf("checkForUpdates() error = %v, want ErrMandatoryUpdate", err)
+	}
+}
+
+func TestCheckForUpdateMandatory(t *testing.T) {
+	useVersion(t, "1.0.0")
+	server := serveRelease(t, GitHubRelease{TagName: "v1.2.0", MinimumVersion: "v1.1.0"})
+	useKeployHome(t, "update_pref=yes\nupdate_url="+server.URL+"\n")
+	prompts := usePrompter(t, DecisionRemindLater)
+
+	if err := CheckForUpdate(context.Background(), zap.NewNop()); !errors.Is(err, ErrMandatoryUpdate) {
+		t.Fatalf("CheckForUpdate() error = %v, want ErrMandatoryUpdate", err)
+	}
+	if len(*prompts) != 0 {
+		t.Fatalf("prompts = %+v, want none for a mandatory update", *prompts)
+	}
+}
+
+func TestCheckForUpdatesNotMandatory(t *testing.T) {
+	useVersion(t, "1.1.0")
+	server := serveRelease(t, GitHubRelease{TagName: "v1.2.0", MinimumVersion: "v1.1.0"})
+	useKeployHome(t, "update_pref=yes\nupdate_url="+server.URL+"\n")
+	prompts := usePrompter(t, DecisionRemindLater)
+
+	if _, err := checkForUpdates(context.Background(), zap.NewNop()); err != nil {
+		t.Fatalf("checkForUpdates() error = %v, want nil", err)
+	}
+	if len(*prompts) != 1 || (*prompts)[0].Latest != "v1.2.0" {
+		t.Fatalf("prompts = %+v, want a single prompt offering v1.2.0", *prompts)
+	}
+}
+
+func TestCheckForUpdatesCachedMandatory(t *testing.T) {
+	useVersion(t, "1.0.0")
+	transport := &countingTransport{release: GitHubRelease{TagName: "v1.2.0"}}
+	useTransport(t, transport)
+	useKeployHome(t, "update_pref=no\n")
+	usePrompter(t, DecisionRemindLater)
+	if err := writeReleaseCache(GitHubRelease{TagName: "v1.2.0", MinimumVersion: "v1.1.0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := checkForUpdates(context.Background(), zap.NewNop()); !errors.Is(err, ErrMandatoryUpdate) {
+		t.Fatalf("checkForUpdates() error = %v, want ErrMandatoryUpdate", err)
+	}
+	if got := transport.requests.Load(); got != 0 {
+		t.Fatalf("the release was fetched %d times, want the cached minimum version enforced", got)
+	}
+}
+
+func TestParsePromptAnswer(t *testing.T) {
+	tests := []struct {
+		response string
+		def      bool
+		want     bool
+	}{
+		{"y", false, true},
+		{" Yes\n", false, true},
+		{"n", true, false},
+		{"NO", true, false},
+		{"", false, false},
+		{"", true, true},
+		{"maybe", true, true},
+	}
+	for _, tt := range tests {
+		if got := parsePromptAnswer(tt.response, tt.def); got != tt.want {
+			t.Errorf("parsePromptAnswer(%q, %v) = %v, want %v", tt.response, tt.def, got, tt.want)
+		}
+	}
+}
+
+func TestUpdatePromptDefault(t *testing.T) {
+	useKeployHome(t, "")
+	if updatePromptDefault() {
+		t.Fatal("updatePromptDefault() = true, want no by default")
+	}
+	useKeployHome(t, "update_prompt_default=yes\n")
+	if !updatePromptDefault() {
+		t.Fatal("updatePromptDefault() = false with update_prompt_default=yes")
+	}
+}
+
+func TestIsPackageManaged(t *testing.T) {
+	useKeployHome(t, "install_method="+InstallMethodHomebrew+"\n")
+	if managed, upgrade := IsPackageManaged(); !managed || upgrade != updateInstruction(InstallMethodHomebrew) {
+		t.Fatalf("IsPackageManaged() = %v, %q, want the Homebrew upgrade command", managed, upgrade)
+	}
+	useKeployHome(t, "install_method="+InstallMethodScript+"\n")
+	if managed, _ := IsPackageManaged(); managed {
+		t.Fatal("IsPackageManaged() = true for a binary installed by the script")
+	}
+}
+
+func TestNextUpdateCheck(t *testing.T) {
+	useVersion(t, "1.1.0")
+	server := serveRelease(t, GitHubRelease{TagName: "v1.2.0"})
+	useKeployHome(t, "update_pref=yes\nupdate_check_interval=1h\nupdate_url="+server.URL+"\n")
+	usePrompter(t, DecisionRemindLater)
+
+	before := time.Now()
+	next, err := NextUpdateCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Before(before) || next.After(time.Now()) {
+		t.Fatalf("NextUpdateCheck() = %v, want now before the first check", next)
+	}
+
+	if _, err := checkForUpdates(context.Background(), zap.NewNop()); err != nil {
+		t.Fatalf("checkForUpdates() error = %v", err)
+	}
+	next, err = NextUpdateCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Before(before.Add(time.Hour)) || next.After(time.Now().Add(time.Hour)) {
+		t.Fatalf("NextUpdateCheck() = %v, want an hour after the check", next)
+	}
+}
+
+func TestCheckForUpdatesInvalidVersion(t *testing.T) {
+	useVersion(t, "2-dev")
+	transport := &countingTransport{release: GitHubRelease{TagName: "v1.2.0", MinimumVersion: "v1.1.0"}}
+	useTransport(t, transport)
+	useKeployHome(t, "update_pref=yes\n")
+	prompts := usePrompter(t, DecisionRemindLater)
+
+	if _, err := checkForUpdates(context.Background(), zap.NewNop()); err != nil {
+		t.Fatalf("checkForUpdates() error = %v, want the check skipped", err)
+	}
+	if transport.requests.Load() != 0 || len(*prompts) != 0 {
+		t.Fatal("the update check ran with an invalid current version")
+	}
+}
+
+func TestParseUpdateDecision(t *testing.T) {
+	tests := []struct {
+		response string
+		want     Decision
+	}{
+		{"u", DecisionUpdate},
+		{"Update", DecisionUpdate},
+		{"s", DecisionSkipVersion},
+		{"w", DecisionSnooze},
+		{" snooze\n", DecisionSnooze},
+		{"d", DecisionDisableChecks},
+		{"l", DecisionRemindLater},
+		{"", DecisionRemindLater},
+		{"maybe", DecisionRemindLater},
+	}
+	for _, tt := range tests {
+		if got := parseUpdateDecision(tt.response, DecisionRemindLater); got != tt.want {
+			t.Errorf("parseUpdateDecision(%q) = %v, want %v", tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestApplyUpdateDecisionSnooze(t *testing.T) {
+	useKeployHome(t, "update_pref=yes\n")
+	prompt := UpdatePrompt{Current: "v1.1.0", Latest: "v1.2.0"}
+
+	if err := applyUpdateDecision(zap.NewNop(), prompt, DecisionSnooze); err != nil {
+		t.Fatal(err)
+	}
+	until, ok := snoozedUntil()
+	if !ok || until.Before(time.Now().Add(defaultSnoozeDuration-time.Minute)) {
+		t.Fatalf("snoozedUntil() = %v, %v, want the notifications snoozed for a week", until, ok)
+	}
+}
+
+func TestCheckForUpdatesSnoozed(t *testing.T) {
+	useVersion(t, "1.1.0")
+	for _, tt := range []struct {
+		name     string
+		until    time.Time
+		requests int32
+	}{
+		{name: "snoozed", until: time.Now().Add(time.Hour), requests: 0},
+		{name: "snooze over", until: time.Now().Add(-time.Hour), requests: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &countingTransport{release: GitHubRelease{TagName: "v1.2.0"}}
+			useTransport(t, transport)
+			useKeployHome(t, "update_pref=yes\n"+snoozeKey+"="+tt.until.Format(time.RFC3339)+"\n")
+			usePrompter(t, DecisionRemindLater)
+
+			if _, err := checkForUpdates(context.Background(), zap.NewNop()); err != nil {
+				t.Fatal(err)
+			}
+			if got := transport.requests.Load(); got != tt.requests {
+				t.Fatalf("requests = %d, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestApplyUpdateDecisionSkipVersion(t *testing.T) {
+	useVersion(t, "1.1.0")
+	useKeployHome(t, "update_pref=yes\n")
+	prompt := UpdatePrompt{Current: "v1.1.0", Latest: "v1.2.0"}
+
+	if err := applyUpdateDecision(zap.NewNop(), prompt, DecisionSkipVersion); err != nil {
+		t.Fatal(err)
+	}
+	if !IsVersionSkipped("v1.2.0") {
+		t.Fatal("v1.2.0 is not skipped after choosing to skip it")
+	}
+}
+
+func TestCheckForUpdatesDailyRetriesFailedCheck(t *testing.T) {
+	useVersion(t, "1.2.0")
+	var fail atomic.Bool
+	fail.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fail.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(GitHubRelease{TagName: "v1.2.0"})
+	}))
+	t.Cleanup(server.Close)
+	useKeployHome(t, "update_pref=yes\nupdate_check_daily=true\nupdate_trace=true\nnetwork_retries=0\nupdate_url="+server.URL+"\n")
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	if _, err := checkForUpdates(context.Background(), logger); err == nil {
+		t.Fatal("checkForUpdates() succeeded although the release could not be fetched")
+	}
+	fail.Store(false)
+	if _, err := checkForUpdates(context.Background(), logger); err != nil {
+		t.Fatalf("checkForUpdates() error = %v", err)
+	}
+	if logs.FilterMessageSnippet("already checked today").Len() != 0 {
+		t.Fatalf("logs = %v, want the failed check retried", logs.All())
+	}
+	if _, err := checkForUpdates(context.Background(), logger); err != nil {
+		t.Fatalf("checkForUpdates() error = %v", err)
+	}
+	if logs.FilterMessageSnippet("already checked today").Len() != 1 {
+		t.Fatalf("logs = %v, want the check skipped after the successful one", logs.All())
+	}
+}
+
+func TestCheckForUpdatesDailyConcurrent(t *testing.T) {
+	useVersion(t, "1.2.0")
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		// Slow enough for the second check to start while the first one fetches.
+		time.Sleep(100 * time.Millisecond)
+		_ = json.NewEncoder(w).Encode(GitHubRelease{TagName: "v1.2.0"})
+	}))
+	t.Cleanup(server.Close)
+	useKeployHome(t, "update_pref=yes\nupdate_check_daily=true\nupdate_url="+server.URL+"\n")
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := checkForUpdates(context.Background(), zap.NewNop())
+			errs <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("checkForUpdates() error = %v", err)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("the release was fetched %d times by two concurrent checks, want once", got)
+	}
+}
+
+func TestLockDailyUpdateCheckStale(t *testing.T) {
+	home := useKeployHome(t, "")
+	lock := filepath.Join(home, dailyCheckLockFile)
+	if err := os.WriteFile(lock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lockDailyUpdateCheck(zap.NewNop()); ok {
+		t.Fatal("lockDailyUpdateCheck() took the lock held by another process")
+	}
+
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(lock, old, old); err != nil {
+		t.Fatal(err)
+	}
+	unlock, ok := lockDailyUpdateCheck(zap.NewNop())
+	if !ok {
+		t.Fatal("lockDailyUpdateCheck() didn't take over the lock left by a crashed process")
+	}
+	unlock()
+	if _, err := os.Stat(lock); !os.IsNotExist(err) {
+		t.Fatalf("the lock was kept after unlock: %v", err)
+	}
+}
+
+func TestLogWarningVerbosity(t *testing.T) {
+	tests := []struct {
+		verbosity     string
+		wantLines     int
+		wantChangelog bool
+	}{
+		{VerbosityQuiet, 1, false},
+		{VerbosityNormal, 3, false},
+		{"unknown", 3, false},
+		{VerbosityVerbose, 6, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.verbosity, func(t *testing.T) {
+			useKeployHome(t, "update_prompt_verbosity="+tt.verbosity+"\n")
+			out := captureStdout(t, func() { logWarning("1.0.0", "1.1.0", "Faster startup", 0) })
+			if got := strings.Count(out, "\n"); got != tt.wantLines {
+				t.Fatalf("the notice has %d lines, want %d:\n%s", got, tt.wantLines, out)
+			}
+			if got := strings.Contains(out, "Faster startup"); got != tt.wantChangelog {
+				t.Fatalf("changelog shown = %v, want %v:\n%s", got, tt.wantChangelog, out)
+			}
+		})
+	}
+}
+
+func TestUpdateURLs(t *testing.T) {
+	useKeployHome(t, "update_url=https://primary.example/latest\n")
+	if got := UpdateURLs(); len(got) != 1 || got[0] != "https://primary.example/latest" {
+		t.Fatalf("UpdateURLs() = %v, want update_url alone", got)
+	}
+	useKeployHome(t, "update_urls=https://a.example/latest, https://b.example/latest\n")
+	if got := UpdateURLs(); len(got) != 2 || got[0] != "https://a.example/latest" || got[1] != "https://b.example/latest" {
+		t.Fatalf("UpdateURLs() = %v, want the mirrors in order", got)
+	}
+}
+
+func TestLogWarningDownloadSize(t *testing.T) {
+	useKeployHome(t, "")
+	out := captureStdout(t, func() { logWarning("1.0.0", "1.1.0", "", 25480396) })
+	if !strings.Contains(out, "Download size: 24.3 MB\n") {
+		t.Fatalf("the notice doesn't show the download size:\n%s", out)
+	}
+}
@@ -151,6 +151,31 @@ func LogError(logger *zap.Logger, err error, msg string, fields ...zap.Field) {
 	}
 }
 
+// LogErrorCtx is LogError for errors caused by ctx. When err is the error of a canceled
+// or expired context, the cause of the cancellation, e.g. the command timeout set with
+// NewCtxWithTimeout, is logged along with it. A context canceled with a cause, e.g. by
+// Fatal, was not interrupted by the user, its context.Canceled error is logged too.
+func LogErrorCtx(ctx context.Context, logger *zap.Logger, err error, msg string, fields ...zap.Field) {
+	if ctx != nil && logger != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		if cause := context.Cause(ctx); cause != nil && cause != err && cause != ctx.Err() {
+			logger.Error(msg, append(fields, zap.Error(err), zap.NamedError("cause", cause))...)
+			return
+		}
+	}
+	LogError(logger, err, msg, fields...)
+}
+
+// SafeClose closes c and logs the error, if any. It is meant for deferred closes
+// whose error would otherwise be silently dropped.
+func SafeClose(logger *zap.Logger, c io.Closer, name string) {
+	if c == nil {
+		return
+	}
+	if err := c.Close(); err != nil {
+		LogError(logger, err, "failed to close "+name)
+	}
+}
+
 func DeleteFileIfNotExists(logger *zap.Logger, name string) (err error) {
 	//Check if file exists
 	_, err = os.Stat(name)
@@ -170,6 +195,17 @@ func DeleteFileIfNotExists(logger *zap.Logger, name string) (err error) {
 type GitHubRelease struct {
 	TagName string `json:"tag_name"`
 	Body    string `json:"body"`
+	// MinimumVersion is the minimum supported version, when the update endpoint announces one.
+	MinimumVersion string `json:"minimum_version,omitempty"`
+	// Assets are the files attached to the release.
+	Assets []ReleaseAsset `json:"assets,omitempty"`
+}
+
+// ReleaseAsset is a file attached to a release.
+type ReleaseAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	Size               int64  `json:"size"`
 }
 
 var ErrGitHubAPIUnresponsive = errors.New("GitHub API is unresponsive")
@@ -266,6 +302,24 @@ func Recover(logger *zap.Logger) {
 	}
 }
 
+// GuardGoroutine runs fn, typically as the body of a goroutine: go utils.GuardGoroutine(fn).
+// A panic in fn is recovered and logged with its stack trace, then keploy is stopped
+// through Fatal so that the shutdown hooks run instead of the whole process crashing.
+func GuardGoroutine(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("panic in goroutine: %v", r)
+			if logger := currentLogger(); logger != nil {
+				LogError(logger, err, "recovered from a panic", zap.String("stack trace", string(debug.Stack())))
+			} else {
+				fmt.Println(Emoji+"Recovered from a panic:", r, "\n"+string(debug.Stack()))
+			}
+			Fatal(err)
+		}
+	}()
+	fn()
+}
+
 // GenerateGithubActions generates a GitHub Actions workflow file for Keploy
 func GenerateGithubActions(logger *zap.Logger, appCmd string) {
 	// Determine the path based on the alias "keploy"
@@ -311,20 +365,94 @@ jobs:
 
 // GetLatestGitHubRelease fetches the latest version and release body from GitHub releases with a timeout.
 func GetLatestGitHubRelease(ctx context.Context, logger *zap.Logger) (GitHubRelease, error) {
-	// GitHub repository details
-	repoOwner := "keploy"
-	repoName := "keploy"
+	return fetchLatestRelease(ctx, logger, 4*time.Second)
+}
 
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", repoOwner, repoName)
+const (
+	// releaseFetchTimeout bounds the release fetch of an explicit update.
+	releaseFetchTimeout = 30 * time.Second
+	// releaseFetchProgressInterval is how often the user is told the fetch is still running.
+	releaseFetchProgressInterval = 3 * time.Second
+)
+
+// GetLatestGitHubReleaseWithProgress fetches the latest release for commands the user
+// runs explicitly, e.g. keploy update. Unlike GetLatestGitHubRelease it tolerates slow
+// networks: a "still fetching" message is logged every few seconds so the terminal
+// doesn't look frozen, and the fetch gives up with a clear error after releaseFetchTimeout.
+func GetLatestGitHubReleaseWithProgress(ctx context.Context, logger *zap.Logger) (GitHubRelease, error) {
+	return getLatestReleaseWithProgress(ctx, logger, releaseFetchTimeout, releaseFetchProgressInterval)
+}
+
+func getLatestReleaseWithProgress(ctx context.Context, logger *zap.Logger, timeout, progressInterval time.Duration) (GitHubRelease, error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	client := http.Client{
-		Timeout: 4 * time.Second,
+	var release GitHubRelease
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The client timeout is disabled, the fetch is bounded by fetchCtx.
+		release, err = fetchLatestRelease(fetchCtx, logger, 0)
+	}()
+
+	start := time.Now()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			if err != nil && ctx.Err() == nil && errors.Is(fetchCtx.Err(), context.DeadlineExceeded) {
+				return GitHubRelease{}, fmt.Errorf("%w: no response after %s, check your network connection and try again", ErrGitHubAPIUnresponsive, timeout)
+			}
+			return release, err
+		case <-ticker.C:
+			// Unlike stdout, which may be parsed, the log and stderr are meant for the user.
+			if logger != nil {
+				logger.Info("still fetching the latest release...", zap.Duration("elapsed", time.Since(start).Round(time.Second)))
+			} else {
+				fmt.Fprintln(os.Stderr, "still fetching the latest release...")
+			}
+		}
+	}
+}
+
+// fetchLatestRelease fetches the latest release from the update endpoints, trying them
+// in order until one answers, see UpdateURLs. The error of the last endpoint is returned
+// when none does.
+func fetchLatestRelease(ctx context.Context, logger *zap.Logger, timeout time.Duration) (GitHubRelease, error) {
+	var err error
+	for i, apiURL := range UpdateURLs() {
+		var release GitHubRelease
+		release, err = fetchLatestReleaseFrom(ctx, logger, apiURL, timeout)
+		if err == nil {
+			if logger != nil {
+				if i > 0 {
+					logger.Info("fetched the latest release from a fallback update endpoint", zap.String("url", apiURL))
+				} else {
+					logger.Debug("fetched the latest release", zap.String("url", apiURL))
+				}
+			}
+			return release, nil
+		}
+		if ctx.Err() != nil {
+			return GitHubRelease{}, err
+		}
+		if logger != nil {
+			logger.Debug("failed to fetch the latest release", zap.String("url", apiURL), zap.Error(err))
+		}
 	}
+	return GitHubRelease{}, err
+}
+
+func fetchLatestReleaseFrom(ctx context.Context, logger *zap.Logger, apiURL string, timeout time.Duration) (GitHubRelease, error) {
+	client := HTTPClient(timeout)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return GitHubRelease{}, err
 	}
+	setUpdateRequestHeaders(req)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -339,6 +467,9 @@ func GetLatestGitHubRelease(ctx context.Context, logger *zap.Logger) (GitHubRele
 			LogError(logger, err, "failed to close response body")
 		}
 	}()
+	if resp.StatusCode != http.StatusOK {
+		return GitHubRelease{}, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, apiURL)
+	}
 
 	var release GitHubRelease
 	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
@@ -727,16 +858,17 @@ func FileExists(path string) (bool, error) {
 	return fileInfo.Mode().IsRegular(), nil
 }
 
-// ExpandPath expands a given path, replacing the tilde with the user's home directory
+// ExpandPath expands a given path, replacing a leading tilde ("~" or "~/") with the user's home directory.
+// Paths not starting with a tilde are returned unchanged.
 func ExpandPath(path string) (string, error) {
-	if strings.HasPrefix(path, "~/") {
-		homeDir, err := getHomeDir()
-		if err != nil {
-			return "", err
-		}
-		return strings.Replace(path, "~", homeDir, 1), nil
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
 	}
-	return path, nil
+	return strings.Replace(path, "~", homeDir, 1), nil
 }
 
 // getHomeDir retrieves the appropriate home directory based on the execution context
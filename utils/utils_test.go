@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestExpandPath(t *testing.T) {
+	t.Setenv("SUDO_USER", "")
+	usr, err := user.Current()
+	if err != nil {
+		t.Skip("current user unavailable:", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"~", usr.HomeDir},
+		{"~/keploy", filepath.Join(usr.HomeDir, "keploy")},
+		{"/tmp/keploy", "/tmp/keploy"},
+		{"./keploy", "./keploy"},
+		{"~user/keploy", "~user/keploy"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := ExpandPath(tt.path)
+		if err != nil {
+			t.Fatalf("ExpandPath(%q) error = %v", tt.path, err)
+		}
+		if got != tt.want {
+			t.Errorf("ExpandPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+// closer is an io.Closer returning err.
+type closer struct{ err error }
+
+func (c closer) Close() error { return c.err }
+
+func TestSafeClose(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	logger := zap.New(core)
+
+	SafeClose(logger, closer{}, "the file")
+	SafeClose(logger, nil, "the file")
+	if logs.Len() != 0 {
+		t.Fatalf("logs = %v, want nothing logged for a successful close", logs.All())
+	}
+
+	SafeClose(logger, closer{err: errors.New("disk full")}, "the file")
+	if logs.FilterMessage("failed to close the file").Len() != 1 {
+		t.Fatalf("logs = %v, want the close error logged", logs.All())
+	}
+}
+
+func TestGetLatestReleaseWithProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	useKeployHome(t, "update_url="+server.URL+"\n")
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	_, err := getLatestReleaseWithProgress(context.Background(), zap.New(core), 200*time.Millisecond, 20*time.Millisecond)
+	if !errors.Is(err, ErrGitHubAPIUnresponsive) {
+		t.Fatalf("getLatestReleaseWithProgress() error = %v, want ErrGitHubAPIUnresponsive", err)
+	}
+	if logs.FilterMessage("still fetching the latest release...").Len() == 0 {
+		t.Fatalf("logs = %v, want the progress of the fetch logged", logs.All())
+	}
+}
+
+func TestFetchLatestReleaseFallsBackToMirrors(t *testing.T) {
+	blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(blocked.Close)
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(GitHubRelease{TagName: "v2.3.4"})
+	}))
+	t.Cleanup(mirror.Close)
+	useKeployHome(t, "update_url="+mirror.URL+"/unused\nupdate_urls="+blocked.URL+", "+mirror.URL+"\n")
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	release, err := fetchLatestRelease(context.Background(), zap.New(core), time.Second)
+	if err != nil || release.TagName != "v2.3.4" {
+		t.Fatalf("fetchLatestRelease() = %+v, %v, want the release of the mirror", release, err)
+	}
+	if logs.FilterMessage("fetched the latest release from a fallback update endpoint").Len() != 1 {
+		t.Fatalf("logs = %v, want the fallback logged", logs.All())
+	}
+
+	useKeployHome(t, "update_urls="+blocked.URL+"\n")
+	if _, err := fetchLatestRelease(context.Background(), zap.NewNop(), time.Second); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("fetchLatestRelease() error = %v, want the status of the last endpoint", err)
+	}
+}
+
+func TestGuardGoroutine(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	SetLogger(zap.New(core))
+	ctx, cancel := context.WithCancelCause(context.Background())
+	setCancelFuncs(func() { cancel(nil) }, cancel)
+	t.Cleanup(ResetForTesting)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		GuardGoroutine(func() { panic("nil map") })
+	}()
+	<-done
+
+	if cause := context.Cause(ctx); cause == nil || cause.Error() != "panic in goroutine: nil map" {
+		t.Fatalf("context.Cause() = %v, want the recovered panic", cause)
+	}
+	if logs.FilterMessage("recovered from a panic").Len() != 1 {
+		t.Fatalf("logs = %v, want the panic logged", logs.All())
+	}
+}
+
+func TestLogErrorCtxCause(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	logger := zap.New(core)
+
+	// A plain cancellation is an interruption by the user, it isn't logged.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	LogErrorCtx(ctx, logger, ctx.Err(), "failed to update")
+	if n := logs.Len(); n != 0 {
+		t.Fatalf("logged %d entries for a plain cancellation, want none", n)
+	}
+
+	ctx, cancelCause := context.WithCancelCause(context.Background())
+	cancelCause(errors.New("fatal error: disk full"))
+	LogErrorCtx(ctx, logger, ctx.Err(), "failed to update")
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries for a cancellation with a cause, want 1", len(entries))
+	}
+	if cause := entries[0].ContextMap()["cause"]; cause != "fatal error: disk full" {
+		t.Fatalf("cause = %v, want the cause of the cancellation", cause)
+	}
+}
+
+func TestLogErrorCtxCommandTimeout(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	ctx, cancel := NewCtxWithTimeout(time.Millisecond)
+	t.Cleanup(ResetForTesting)
+	defer cancel()
+	<-ctx.Done()
+
+	LogErrorCtx(ctx, zap.New(core), ctx.Err(), "failed to update")
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(entries))
+	}
+	if cause, _ := entries[0].ContextMap()["cause"].(string); !strings.Contains(cause, "command timeout of 1ms exceeded") {
+		t.Fatalf("cause = %q, want the command timeout", cause)
+	}
+}
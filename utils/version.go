@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SemVer is a parsed semantic version (https://semver.org).
+type SemVer struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease []string
+	Build      string
+}
+
+// ParseVersion parses a semantic version. A leading "v" is accepted.
+func ParseVersion(version string) (SemVer, error) {
+	var v SemVer
+	s := strings.TrimPrefix(strings.TrimSpace(version), "v")
+
+	s, v.Build, _ = strings.Cut(s, "+")
+	core, pre, hasPre := strings.Cut(s, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return SemVer{}, fmt.Errorf("invalid version %q: expected MAJOR.MINOR.PATCH", version)
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		n, err := parseNumericIdentifier(part)
+		if err != nil {
+			return SemVer{}, fmt.Errorf("invalid version %q: %v", version, err)
+		}
+		numbers[i] = n
+	}
+	v.Major, v.Minor, v.Patch = numbers[0], numbers[1], numbers[2]
+
+	if hasPre {
+		for _, id := range strings.Split(pre, ".") {
+			if id == "" {
+				return SemVer{}, fmt.Errorf("invalid version %q: empty pre-release identifier", version)
+			}
+			if isNumeric(id) {
+				if _, err := parseNumericIdentifier(id); err != nil {
+					return SemVer{}, fmt.Errorf("invalid version %q: %v", version, err)
+				}
+			}
+			v.PreRelease = append(v.PreRelease, id)
+		}
+	}
+	return v, nil
+}
+
+// String returns the version in the "vMAJOR.MINOR.PATCH[-PRERELEASE][+BUILD]" form.
+func (v SemVer) String() string {
+	s := fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.PreRelease) > 0 {
+		s += "-" + strings.Join(v.PreRelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v is lower, equal or greater than o following the
+// semver precedence rules. Build metadata is ignored.
+func (v SemVer) Compare(o SemVer) int {
+	if c := compareInts(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	// A version without pre-release has a higher precedence than one with it.
+	switch {
+	case len(v.PreRelease) == 0 && len(o.PreRelease) == 0:
+		return 0
+	case len(v.PreRelease) == 0:
+		return 1
+	case len(o.PreRelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.PreRelease) && i < len(o.PreRelease); i++ {
+		a, b := v.PreRelease[i], o.PreRelease[i]
+		aNum, bNum := isNumeric(a), isNumeric(b)
+		switch {
+		case aNum && bNum:
+			an, _ := strconv.Atoi(a)
+			bn, _ := strconv.Atoi(b)
+			if c := compareInts(an, bn); c != 0 {
+				return c
+			}
+		case aNum:
+			// Numeric identifiers have a lower precedence than alphanumeric ones.
+			return -1
+		case bNum:
+			return 1
+		default:
+			if c := strings.Compare(a, b); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInts(len(v.PreRelease), len(o.PreRelease))
+}
+
+var (
+	parsedVersionMu  sync.Mutex
+	parsedVersionRaw string
+	parsedVersion    SemVer
+	parsedVersionErr error
+	parsedVersionSet bool
+)
+
+// ParsedVersion returns the running keploy version, validated. Version is injected at
+// build time and may be malformed, e.g. by a bad ldflags value, so the code comparing
+// versions must use this instead of the raw string. The result is computed once.
+func ParsedVersion() (SemVer, error) {
+	parsedVersionMu.Lock()
+	defer parsedVersionMu.Unlock()
+	if !parsedVersionSet || parsedVersionRaw != Version {
+		parsedVersionRaw = Version
+		parsedVersionSet = true
+		if strings.TrimSpace(Version) == "" {
+			parsedVersion, parsedVersionErr = SemVer{}, fmt.Errorf("the keploy version is not set")
+		} else {
+			parsedVersion, parsedVersionErr = ParseVersion(Version)
+		}
+	}
+	return parsedVersion, parsedVersionErr
+}
+
+// DisplayVersion normalizes a version for the messages shown to the user: it has a
+// single leading "v" and no build metadata, e.g. "1.2.3-dev+abcd" gives "v1.2.3-dev".
+// Strings not starting with a number are returned trimmed. Versions must still be
+// compared with CompareVersions, not on their display form.
+func DisplayVersion(v string) string {
+	v = strings.TrimSpace(v)
+	core := strings.TrimLeft(v, "vV")
+	if core == "" || core[0] < '0' || core[0] > '9' {
+		return v
+	}
+	core, _, _ = strings.Cut(core, "+")
+	return "v" + core
+}
+
+// CompareVersions compares two semantic versions and returns -1, 0 or 1 if a is lower,
+// equal or greater than b. It is the single place defining version ordering in keploy.
+func CompareVersions(a, b string) (int, error) {
+	va, err := ParseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := ParseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+func parseNumericIdentifier(s string) (int, error) {
+	if !isNumeric(s) {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("%q has a leading zero", s)
+	}
+	return strconv.Atoi(s)
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
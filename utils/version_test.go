@@ -0,0 +1,70 @@
+package utils
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.0", "v1.2.0", 0},
+		{"v1.2.0", "v1.10.0", -1},
+		{"v2.0.0", "v1.99.99", 1},
+		{"v1.2.0-beta.1", "v1.2.0", -1},
+		{"v1.2.0-alpha", "v1.2.0-alpha.1", -1},
+		{"v1.2.0-alpha.2", "v1.2.0-alpha.10", -1},
+		{"v1.2.0-alpha.1", "v1.2.0-alpha.beta", -1},
+		{"v1.2.0+build.1", "v1.2.0+build.2", 0},
+	}
+	for _, tt := range tests {
+		got, err := CompareVersions(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("CompareVersions(%q, %q) error = %v", tt.a, tt.b, err)
+		}
+		if got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseVersionInvalid(t *testing.T) {
+	for _, version := range []string{"", "1.2", "v1.2.x", "1.02.0", "v1.2.0-", "v1.2.0-beta..1"} {
+		if _, err := ParseVersion(version); err == nil {
+			t.Errorf("ParseVersion(%q) accepted an invalid version", version)
+		}
+	}
+}
+
+func TestParsedVersion(t *testing.T) {
+	previous := Version
+	t.Cleanup(func() { Version = previous })
+
+	Version = "1.2.3"
+	if v, err := ParsedVersion(); err != nil || v.String() != "v1.2.3" {
+		t.Fatalf("ParsedVersion() = %v, %v, want v1.2.3", v, err)
+	}
+	for _, invalid := range []string{"", "2-dev", "1.2.3; rm -rf"} {
+		Version = invalid
+		if _, err := ParsedVersion(); err == nil {
+			t.Errorf("ParsedVersion() accepted the version %q", invalid)
+		}
+	}
+}
+
+func TestDisplayVersion(t *testing.T) {
+	tests := []struct {
+		version, want string
+	}{
+		{"1.2.3", "v1.2.3"},
+		{"v1.2.3", "v1.2.3"},
+		{"vv1.2.3", "v1.2.3"},
+		{" V1.2.3-dev+abcd ", "v1.2.3-dev"},
+		{"latest", "latest"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := DisplayVersion(tt.version); got != tt.want {
+			t.Errorf("DisplayVersion(%q) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}